@@ -0,0 +1,9 @@
+package lists
+
+import "embed"
+
+// Assets holds the templates and public files so the binaries can run
+// outside of the repo.
+//
+//go:embed html gmi public
+var Assets embed.FS
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+
+	"git.sr.ht/~erock/lists.sh/internal"
+	"git.sr.ht/~erock/lists.sh/internal/storage"
+)
+
+// Persists the metadata and tags of the posts uploaded before metadata was
+// kept alongside them.  It is safe to run more than once, posts that already
+// have metadata are skipped.
+func main() {
+	cfg := internal.NewConfigSite()
+	logger := cfg.Logger
+	dbh := storage.NewDB(&cfg.ConfigCms)
+	defer dbh.Close()
+
+	posts, err := dbh.FindPostsWithoutData(cfg.Space)
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	for _, post := range posts {
+		postData := internal.CreatePostData(cfg.ParseText(post.Text))
+		err = dbh.UpdatePostData(post.ID, postData)
+		if err != nil {
+			logger.Fatal(err)
+		}
+
+		err = dbh.ReplaceTagsForPost(postData.Tags, post.ID)
+		if err != nil {
+			logger.Fatal(err)
+		}
+	}
+
+	fmt.Printf("%d post(s) reparsed\n", len(posts))
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+
+	"git.sr.ht/~erock/lists.sh/internal"
+	"git.sr.ht/~erock/lists.sh/internal/storage"
+)
+
+// Lists the users that signed up before their name was reserved.  Their
+// blogs are shadowed by site routes so they need to be contacted and renamed
+// by hand.
+func main() {
+	cfg := internal.NewConfigSite()
+	dbh := storage.NewDB(&cfg.ConfigCms)
+	defer dbh.Close()
+
+	found := 0
+	for _, name := range storage.ReservedNames {
+		user, err := dbh.FindUserForName(name)
+		if err != nil {
+			continue
+		}
+
+		found++
+		fmt.Printf("%s\t%s\t%s\n", user.Name, user.ID, user.CreatedAt.Format("2006-01-02"))
+	}
+
+	fmt.Printf("%d existing user(s) collide with reserved names\n", found)
+}
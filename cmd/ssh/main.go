@@ -9,11 +9,9 @@ import (
 	"time"
 
 	"git.sr.ht/~erock/lists.sh/internal"
+	"git.sr.ht/~erock/lists.sh/internal/storage"
 	"git.sr.ht/~erock/wish/cms"
-	"git.sr.ht/~erock/wish/cms/db/postgres"
 	"git.sr.ht/~erock/wish/proxy"
-	"git.sr.ht/~erock/wish/send/scp"
-	"git.sr.ht/~erock/wish/send/sftp"
 	"github.com/charmbracelet/wish"
 	bm "github.com/charmbracelet/wish/bubbletea"
 	lm "github.com/charmbracelet/wish/logging"
@@ -37,7 +35,9 @@ func createRouter(handler *internal.DbHandler) proxy.Router {
 				lm.Middleware(),
 			)
 		} else if cmd[0] == "scp" {
-			mdw = append(mdw, scp.Middleware(handler))
+			mdw = append(mdw, internal.ScpMiddleware(handler))
+		} else {
+			mdw = append(mdw, internal.CommandMiddleware(handler), lm.Middleware())
 		}
 
 		return mdw
@@ -46,7 +46,7 @@ func createRouter(handler *internal.DbHandler) proxy.Router {
 
 func withProxy(handler *internal.DbHandler) ssh.Option {
 	return func(server *ssh.Server) error {
-		err := sftp.SSHOption(handler)(server)
+		err := internal.SftpOption(handler)(server)
 		if err != nil {
 			return err
 		}
@@ -60,10 +60,15 @@ func main() {
 	port := internal.GetEnv("PROSE_SSH_PORT", "2222")
 	cfg := internal.NewConfigSite()
 	logger := cfg.Logger
-	dbh := postgres.NewDB(&cfg.ConfigCms)
+	dbh := storage.NewDB(&cfg.ConfigCms)
 	defer dbh.Close()
 	handler := internal.NewDbHandler(dbh, cfg)
 
+	// signups happen in the cms so the database enforces reserved names
+	if err := dbh.SyncReservedNames(cfg.Space, storage.ReservedNames); err != nil {
+		logger.Fatal(err)
+	}
+
 	sshServer := &SSHServer{}
 	s, err := wish.NewServer(
 		wish.WithAddress(fmt.Sprintf("%s:%s", host, port)),
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS post_tags (
+  id uuid NOT NULL DEFAULT uuid_generate_v4(),
+  post_id uuid NOT NULL,
+  name character varying(255) NOT NULL,
+  created_at timestamp with time zone NOT NULL DEFAULT NOW(),
+  CONSTRAINT post_tags_pkey PRIMARY KEY (id),
+  CONSTRAINT unique_tag_for_post UNIQUE (post_id, name),
+  CONSTRAINT fk_post_tags_posts
+    FOREIGN KEY(post_id)
+  REFERENCES posts(id)
+  ON DELETE CASCADE
+  ON UPDATE CASCADE
+);
+
+CREATE INDEX post_tags_name ON post_tags USING btree(name);
+
+INSERT INTO post_tags (post_id, name)
+  SELECT id, jsonb_array_elements_text(data->'tags') FROM posts
+  WHERE jsonb_typeof(data->'tags') = 'array'
+  ON CONFLICT DO NOTHING;
@@ -0,0 +1,7 @@
+ALTER TABLE posts ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+  setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+  setweight(to_tsvector('english', coalesce(description, '')), 'B') ||
+  setweight(to_tsvector('english', coalesce(text, '')), 'C')
+) STORED;
+
+CREATE INDEX posts_search_vector ON posts USING gin(search_vector);
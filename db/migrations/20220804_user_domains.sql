@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS user_domains (
+  id uuid NOT NULL DEFAULT uuid_generate_v4(),
+  user_id uuid NOT NULL,
+  domain character varying(253) NOT NULL,
+  verified_at timestamp with time zone,
+  created_at timestamp with time zone NOT NULL DEFAULT NOW(),
+  CONSTRAINT user_domains_pkey PRIMARY KEY (id),
+  CONSTRAINT unique_domain_for_user UNIQUE (user_id),
+  CONSTRAINT fk_user_domains_app_users
+    FOREIGN KEY(user_id)
+  REFERENCES app_users(id)
+  ON DELETE CASCADE
+  ON UPDATE CASCADE
+);
+
+-- many users can claim a domain but only the one who verifies it serves it
+CREATE UNIQUE INDEX user_domains_verified_domain ON user_domains USING btree(domain) WHERE verified_at IS NOT NULL;
@@ -0,0 +1,32 @@
+-- filled from storage.ReservedNames when the ssh app starts, space is the
+-- app the name is reserved for
+CREATE TABLE IF NOT EXISTS reserved_usernames (
+  space character varying(50) NOT NULL,
+  name character varying(50) NOT NULL,
+  CONSTRAINT reserved_usernames_pkey PRIMARY KEY (space, name)
+);
+
+-- app_users is shared by every app that uses this database so a name is only
+-- rejected for connections whose application_name is the space it is
+-- reserved for, lists sets it on its connection string.  The cms signs users
+-- up with its own connection, which is why this is enforced by a trigger
+-- instead of in go.
+CREATE OR REPLACE FUNCTION check_reserved_username() RETURNS trigger AS $$
+BEGIN
+  IF EXISTS (
+    SELECT 1 FROM reserved_usernames
+    WHERE name = lower(NEW.name) AND space = current_setting('application_name', true)
+  ) THEN
+    RAISE EXCEPTION 'username is reserved, please pick another one'
+      USING ERRCODE = 'check_violation';
+  END IF;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS app_users_reserved_name ON app_users;
+CREATE TRIGGER app_users_reserved_name
+  BEFORE INSERT OR UPDATE OF name ON app_users
+  FOR EACH ROW
+  WHEN (NEW.name IS NOT NULL)
+  EXECUTE FUNCTION check_reserved_username();
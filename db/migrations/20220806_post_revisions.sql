@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS post_revisions (
+  id uuid NOT NULL DEFAULT uuid_generate_v4(),
+  post_id uuid NOT NULL,
+  title character varying(255) NOT NULL,
+  text text NOT NULL DEFAULT '',
+  created_at timestamp with time zone NOT NULL DEFAULT NOW(),
+  CONSTRAINT post_revisions_pkey PRIMARY KEY (id),
+  CONSTRAINT fk_post_revisions_posts
+    FOREIGN KEY(post_id)
+  REFERENCES posts(id)
+  ON DELETE CASCADE
+  ON UPDATE CASCADE
+);
+
+CREATE INDEX post_revisions_post_id ON post_revisions USING btree(post_id, created_at);
+
+-- the current text of every post is its first revision
+INSERT INTO post_revisions (post_id, title, text, created_at)
+  SELECT id, title, text, updated_at FROM posts
+  WHERE NOT EXISTS (SELECT 1 FROM post_revisions WHERE post_revisions.post_id = posts.id);
@@ -1,3 +1,7 @@
+DROP TABLE post_revisions CASCADE;
+DROP TABLE reserved_usernames CASCADE;
+DROP TABLE user_domains CASCADE;
+DROP TABLE post_tags CASCADE;
 DROP TABLE posts CASCADE;
 DROP TABLE app_users CASCADE;
 DROP TABLE public_keys CASCADE;
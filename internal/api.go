@@ -2,18 +2,23 @@ package internal
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"fmt"
 	"html/template"
-	"io/ioutil"
+	"io/fs"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"git.sr.ht/~erock/lists.sh/internal/storage"
 	"git.sr.ht/~erock/lists.sh/pkg"
+	"git.sr.ht/~erock/lists.sh/pkg/render"
 	"git.sr.ht/~erock/wish/cms/db"
-	"git.sr.ht/~erock/wish/cms/db/postgres"
 	"github.com/gorilla/feeds"
+	"go.uber.org/zap"
 	"golang.org/x/exp/slices"
 )
 
@@ -22,49 +27,110 @@ type PageData struct {
 }
 
 type PostItemData struct {
-	URL            template.URL
-	BlogURL        template.URL
-	Username       string
-	Title          string
-	Description    string
-	PublishAtISO   string
-	PublishAt      string
-	UpdatedAtISO   string
-	UpdatedTimeAgo string
-	Padding        string
+	URL            template.URL  `json:"url"`
+	BlogURL        template.URL  `json:"blog_url"`
+	Username       string        `json:"username"`
+	Title          string        `json:"title"`
+	Description    string        `json:"description"`
+	PublishAtISO   string        `json:"publish_at"`
+	PublishAt      string        `json:"-"`
+	UpdatedAtISO   string        `json:"updated_at"`
+	UpdatedTimeAgo string        `json:"-"`
+	Padding        string        `json:"-"`
+	Tags           []TagData     `json:"tags"`
+	Snippet        []SnippetPart `json:"-"`
+}
+
+type TagData struct {
+	Name string       `json:"name"`
+	URL  template.URL `json:"url"`
+}
+
+type TocData struct {
+	ID          string
+	URL         template.URL
+	Value       string
+	IsHeaderTwo bool
 }
 
 type BlogPageData struct {
-	Site      SitePageData
-	PageTitle string
-	URL       template.URL
-	RSSURL    template.URL
-	Username  string
-	Readme    *ReadmeTxt
-	Header    *HeaderTxt
-	Posts     []PostItemData
+	Site       SitePageData
+	PageTitle  string
+	URL        template.URL
+	RSSURL     template.URL
+	ChangesURL template.URL
+	Username   string
+	Readme     *ReadmeTxt
+	Header     *HeaderTxt
+	Posts      []PostItemData
+	Query      string
+	SearchURL  string
+	NextPage   string
+	PrevPage   string
 }
 
 type ReadPageData struct {
-	Site     SitePageData
-	NextPage string
-	PrevPage string
-	Posts    []PostItemData
+	Site      SitePageData
+	Query     string
+	SearchURL string
+	NextPage  string
+	PrevPage  string
+	Posts     []PostItemData
 }
 
 type PostPageData struct {
-	Site         SitePageData
-	PageTitle    string
-	URL          template.URL
-	BlogURL      template.URL
-	Title        string
-	Description  string
-	Username     string
-	BlogName     string
-	ListType     string
-	Items        []*pkg.ListItem
-	PublishAtISO string
-	PublishAt    string
+	Site         SitePageData      `json:"-"`
+	PageTitle    string            `json:"-"`
+	URL          template.URL      `json:"url"`
+	RawURL       template.URL      `json:"raw_url"`
+	HistoryURL   template.URL      `json:"history_url"`
+	ChangesURL   template.URL      `json:"changes_url"`
+	CurrentURL   template.URL      `json:"-"`
+	RevisionID   string            `json:"revision_id,omitempty"`
+	RevisionAt   string            `json:"-"`
+	BlogURL      template.URL      `json:"blog_url"`
+	Title        string            `json:"title"`
+	Description  string            `json:"description"`
+	Username     string            `json:"username"`
+	BlogName     string            `json:"blog_name"`
+	ListType     string            `json:"list_type"`
+	Items        []*pkg.ListItem   `json:"items"`
+	Toc          []TocData         `json:"-"`
+	Content      template.HTML     `json:"-"`
+	PublishAtISO string            `json:"publish_at"`
+	PublishAt    string            `json:"-"`
+	TodosDone    int               `json:"todos_done"`
+	TodosTotal   int               `json:"todos_total"`
+	Tags         []TagData         `json:"tags"`
+	Extra        map[string]string `json:"extra"`
+}
+
+type ItemPageData struct {
+	Site        SitePageData  `json:"-"`
+	PageTitle   string        `json:"-"`
+	URL         template.URL  `json:"url"`
+	PostURL     template.URL  `json:"post_url"`
+	BlogURL     template.URL  `json:"blog_url"`
+	Title       string        `json:"title"`
+	Username    string        `json:"username"`
+	BlogName    string        `json:"blog_name"`
+	Description string        `json:"-"`
+	ListType    string        `json:"list_type"`
+	Item        *pkg.ListItem `json:"item"`
+	Content     template.HTML `json:"-"`
+}
+
+type TagPageData struct {
+	Site      SitePageData
+	PageTitle string
+	Tag       string
+	URL       template.URL
+	RSSURL    template.URL
+	BlogURL   template.URL
+	Username  string
+	NextPage  string
+	PrevPage  string
+	Posts     []PostItemData
 }
 
 type TransparencyPageData struct {
@@ -76,28 +142,66 @@ func isRequestTrackable(r *http.Request) bool {
 	return true
 }
 
-func renderTemplate(templates []string) (*template.Template, error) {
+var templateCache = map[string]*template.Template{}
+var templateMu sync.Mutex
+
+// renderTemplate parses a page along with the shared partials.  Parsed
+// templates are cached unless we are in dev mode.
+func renderTemplate(cfg *ConfigSite, templates []string) (*template.Template, error) {
+	key := strings.Join(templates, ",")
+	if !cfg.Dev {
+		templateMu.Lock()
+		ts, ok := templateCache[key]
+		templateMu.Unlock()
+		if ok {
+			return ts, nil
+		}
+	}
+
 	files := make([]string, len(templates))
 	copy(files, templates)
 	files = append(
 		files,
-		"./html/footer.partial.tmpl",
-		"./html/marketing-footer.partial.tmpl",
-		"./html/base.layout.tmpl",
+		"html/footer.partial.tmpl",
+		"html/marketing-footer.partial.tmpl",
+		"html/base.layout.tmpl",
 	)
 
-	ts, err := template.ParseFiles(files...)
+	ts, err := template.ParseFS(cfg.AssetsFS(), files...)
 	if err != nil {
 		return nil, err
 	}
+
+	if !cfg.Dev {
+		templateMu.Lock()
+		templateCache[key] = ts
+		templateMu.Unlock()
+	}
 	return ts, nil
 }
 
+// parsePageTemplates parses every page up front so a broken template stops
+// the server from starting.
+func parsePageTemplates(cfg *ConfigSite) error {
+	pages, err := fs.Glob(cfg.AssetsFS(), "html/*.page.tmpl")
+	if err != nil {
+		return err
+	}
+
+	for _, page := range pages {
+		_, err := renderTemplate(cfg, []string{page})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func createPageHandler(fname string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		logger := GetLogger(r)
 		cfg := GetCfg(r)
-		ts, err := renderTemplate([]string{fname})
+		ts, err := renderTemplate(cfg, []string{fname})
 
 		if err != nil {
 			logger.Error(err)
@@ -127,13 +231,13 @@ type ReadmeTxt struct {
 	HasItems bool
 	ListType string
 	Items    []*pkg.ListItem
+	Content  template.HTML
 }
 
 func GetUsernameFromRequest(r *http.Request) string {
 	subdomain := GetSubdomain(r)
-	cfg := GetCfg(r)
 
-	if !cfg.IsSubdomains() || subdomain == "" {
+	if subdomain == "" {
 		return GetField(r, 0)
 	}
 	return subdomain
@@ -145,22 +249,29 @@ func blogHandler(w http.ResponseWriter, r *http.Request) {
 	logger := GetLogger(r)
 	cfg := GetCfg(r)
 
+	setVaryHeader(w)
+	format := NegotiateFormat(r)
+
 	user, err := dbpool.FindUserForName(username)
 	if err != nil {
 		logger.Infof("blog not found: %s", username)
-		http.Error(w, "blog not found", http.StatusNotFound)
+		writeFormatError(w, r, format, http.StatusNotFound, "blog not found")
 		return
 	}
 	posts, err := dbpool.FindUpdatedPostsForUser(user.ID, cfg.Space)
 	if err != nil {
 		logger.Error(err)
-		http.Error(w, "could not fetch posts for blog", http.StatusInternalServerError)
+		writeFormatError(w, r, format, http.StatusInternalServerError, "could not fetch posts for blog")
+		return
+	}
+
+	variant := fmt.Sprintf("%s:%d:%s", format, len(posts), r.URL.RawQuery)
+	if CheckNotModified(w, r, NewestUpdatedAt(posts...), variant) {
 		return
 	}
 
-	ts, err := renderTemplate([]string{
-		"./html/blog.page.tmpl",
-		"./html/list.partial.tmpl",
+	ts, err := renderTemplate(cfg, []string{
+		"html/blog.page.tmpl",
 	})
 
 	if err != nil {
@@ -175,10 +286,11 @@ func blogHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	readmeTxt := &ReadmeTxt{}
 
+	tags := FindPostTags(dbpool, logger, posts)
 	postCollection := make([]PostItemData, 0, len(posts))
 	for _, post := range posts {
 		if post.Filename == "_header" {
-			parsedText := pkg.ParseText(post.Text)
+			parsedText := cfg.ParseText(post.Text)
 			if parsedText.MetaData.Title != "" {
 				headerTxt.Title = parsedText.MetaData.Title
 			}
@@ -192,35 +304,86 @@ func blogHandler(w http.ResponseWriter, r *http.Request) {
 				headerTxt.HasItems = true
 			}
 		} else if post.Filename == "_readme" {
-			parsedText := pkg.ParseText(post.Text)
+			parsedText := cfg.ParseText(post.Text)
 			readmeTxt.Items = parsedText.Items
 			readmeTxt.ListType = parsedText.MetaData.ListType
 			if len(readmeTxt.Items) > 0 {
 				readmeTxt.HasItems = true
 			}
+
+			content, err := render.ToString(&render.HTMLRenderer{}, parsedText)
+			if err != nil {
+				logger.Error(err)
+			}
+			readmeTxt.Content = template.HTML(content)
 		} else {
 			p := PostItemData{
 				URL:            template.URL(cfg.PostURL(post.Username, post.Filename)),
 				BlogURL:        template.URL(cfg.BlogURL(post.Username)),
+				Username:       post.Username,
 				Title:          FilenameToTitle(post.Filename, post.Title),
+				Description:    post.Description,
 				PublishAt:      post.PublishAt.Format("02 Jan, 2006"),
 				PublishAtISO:   post.PublishAt.Format(time.RFC3339),
 				UpdatedTimeAgo: TimeAgo(post.UpdatedAt),
 				UpdatedAtISO:   post.UpdatedAt.Format(time.RFC3339),
+				Tags:           CreateTagData(cfg, post.Username, tags[post.ID]),
 			}
 			postCollection = append(postCollection, p)
 		}
 	}
 
 	data := BlogPageData{
-		Site:      *cfg.GetSiteData(),
-		PageTitle: headerTxt.Title,
-		URL:       template.URL(cfg.BlogURL(username)),
-		RSSURL:    template.URL(cfg.RssBlogURL(username)),
-		Readme:    readmeTxt,
-		Header:    headerTxt,
-		Username:  username,
-		Posts:     postCollection,
+		Site:       *cfg.GetSiteData(),
+		PageTitle:  headerTxt.Title,
+		URL:        template.URL(cfg.BlogURL(username)),
+		RSSURL:     template.URL(cfg.RssBlogURL(username)),
+		ChangesURL: template.URL(cfg.BlogChangesURL(username)),
+		Readme:     readmeTxt,
+		Header:     headerTxt,
+		Username:   username,
+		Posts:      postCollection,
+	}
+
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query != "" {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		results, err := dbpool.FindUserPostsBySearch(&db.Pager{Num: 30, Page: page}, query, user.ID, cfg.Space)
+		if err != nil {
+			logger.Error(err)
+			writeFormatError(w, r, format, http.StatusInternalServerError, "could not search posts for blog")
+			return
+		}
+
+		data.Query = query
+		data.Posts = make([]PostItemData, 0, len(results.Data))
+		for _, result := range results.Data {
+			p := PostItemData{
+				URL:            template.URL(cfg.PostURL(result.Username, result.Filename)),
+				BlogURL:        template.URL(cfg.BlogURL(result.Username)),
+				Username:       result.Username,
+				Title:          FilenameToTitle(result.Filename, result.Title),
+				Description:    result.Description,
+				PublishAt:      result.PublishAt.Format("02 Jan, 2006"),
+				PublishAtISO:   result.PublishAt.Format(time.RFC3339),
+				UpdatedTimeAgo: TimeAgo(result.UpdatedAt),
+				UpdatedAtISO:   result.UpdatedAt.Format(time.RFC3339),
+				Snippet:        SplitSnippet(result.Snippet),
+			}
+			data.Posts = append(data.Posts, p)
+		}
+
+		if page < results.Total-1 {
+			data.NextPage = PageURL(cfg.BlogURL(username), query, page+1)
+		}
+		if page > 0 {
+			data.PrevPage = PageURL(cfg.BlogURL(username), query, page-1)
+		}
+	}
+
+	if format != FormatHTML {
+		writeBlogFormat(w, r, format, &data)
+		return
 	}
 
 	err = ts.Execute(w, data)
@@ -230,6 +393,63 @@ func blogHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// CreateTagData links every tag to the page listing the blog's posts for it.
+func CreateTagData(cfg *ConfigSite, username string, tags []string) []TagData {
+	data := make([]TagData, 0, len(tags))
+	for _, tag := range tags {
+		data = append(data, TagData{
+			Name: tag,
+			URL:  template.URL(cfg.BlogTagURL(username, tag)),
+		})
+	}
+	return data
+}
+
+// CreateTocData links every header of the table of contents to its item
+// page, gemini clients can not jump to an anchor so they need the page.
+func CreateTocData(cfg *ConfigSite, username, filename string, headers []*pkg.ListItem) []TocData {
+	data := make([]TocData, 0, len(headers))
+	for _, header := range headers {
+		data = append(data, TocData{
+			ID:          header.ID,
+			URL:         template.URL(cfg.PostItemURL(username, filename, header.ID)),
+			Value:       header.Value,
+			IsHeaderTwo: header.IsHeaderTwo,
+		})
+	}
+	return data
+}
+
+// CreatePostData is the metadata persisted alongside a post on upload.
+func CreatePostData(parsed *pkg.ParsedText) *storage.PostData {
+	return &storage.PostData{
+		Tags:  parsed.MetaData.Tags,
+		Extra: parsed.MetaData.Extra,
+	}
+}
+
+// FindPostTags loads the tags persisted for every post, keyed by post id, so
+// listings do not have to parse each post.  Tags are left out when they
+// cannot be loaded.
+func FindPostTags(dbpool storage.DB, logger *zap.SugaredLogger, posts []*db.Post) map[string][]string {
+	postIDs := make([]string, 0, len(posts))
+	for _, post := range posts {
+		postIDs = append(postIDs, post.ID)
+	}
+
+	tags := map[string][]string{}
+	data, err := dbpool.FindPostDataForPosts(postIDs)
+	if err != nil {
+		logger.Error(err)
+		return tags
+	}
+
+	for postID, d := range data {
+		tags[postID] = d.Tags
+	}
+	return tags
+}
+
 func GetPostTitle(post *db.Post) string {
 	if post.Description == "" {
 		return post.Title
@@ -242,32 +462,69 @@ func GetBlogName(username string) string {
 	return fmt.Sprintf("%s's lists", username)
 }
 
-func postHandler(w http.ResponseWriter, r *http.Request) {
-	username := GetUsernameFromRequest(r)
+func GetFilenameFromRequest(r *http.Request) string {
 	subdomain := GetSubdomain(r)
-	cfg := GetCfg(r)
 
 	var filename string
-	if !cfg.IsSubdomains() || subdomain == "" {
+	if subdomain == "" {
 		filename, _ = url.PathUnescape(GetField(r, 1))
 	} else {
 		filename, _ = url.PathUnescape(GetField(r, 0))
 	}
 
+	return filename
+}
+
+// createPostPageData is shared by the post page and the json api so both
+// describe a post the same way.
+func createPostPageData(cfg *ConfigSite, post *db.Post, username string, blogName string, parsedText *pkg.ParsedText) PostPageData {
+	todosDone, todosTotal := parsedText.TodoProgress()
+	return PostPageData{
+		Site:         *cfg.GetSiteData(),
+		PageTitle:    GetPostTitle(post),
+		URL:          template.URL(cfg.PostURL(post.Username, post.Filename)),
+		RawURL:       template.URL(cfg.RawPostURL(post.Username, post.Filename)),
+		HistoryURL:   template.URL(cfg.PostHistoryURL(post.Username, post.Filename)),
+		ChangesURL:   template.URL(cfg.PostChangesURL(post.Username, post.Filename)),
+		BlogURL:      template.URL(cfg.BlogURL(username)),
+		Description:  post.Description,
+		ListType:     parsedText.MetaData.ListType,
+		Title:        FilenameToTitle(post.Filename, post.Title),
+		PublishAt:    post.PublishAt.Format("02 Jan, 2006"),
+		PublishAtISO: post.PublishAt.Format(time.RFC3339),
+		Username:     username,
+		BlogName:     blogName,
+		Items:        parsedText.Items,
+		Toc:          CreateTocData(cfg, post.Username, post.Filename, parsedText.Toc()),
+		TodosDone:    todosDone,
+		TodosTotal:   todosTotal,
+		Tags:         CreateTagData(cfg, username, parsedText.MetaData.Tags),
+		Extra:        parsedText.MetaData.Extra,
+	}
+}
+
+func postHandler(w http.ResponseWriter, r *http.Request) {
+	username := GetUsernameFromRequest(r)
+	cfg := GetCfg(r)
+
+	filename := GetFilenameFromRequest(r)
 	dbpool := GetDB(r)
 	logger := GetLogger(r)
 
+	setVaryHeader(w)
+	format := NegotiateFormat(r)
+
 	user, err := dbpool.FindUserForName(username)
 	if err != nil {
 		logger.Infof("blog not found: %s", username)
-		http.Error(w, "blog not found", http.StatusNotFound)
+		writeFormatError(w, r, format, http.StatusNotFound, "blog not found")
 		return
 	}
 
 	header, _ := dbpool.FindPostWithFilename("_header", user.ID, cfg.Space)
 	blogName := GetBlogName(username)
 	if header != nil {
-		headerParsed := pkg.ParseText(header.Text)
+		headerParsed := cfg.ParseText(header.Text)
 		if headerParsed.MetaData.Title != "" {
 			blogName = headerParsed.MetaData.Title
 		}
@@ -276,17 +533,22 @@ func postHandler(w http.ResponseWriter, r *http.Request) {
 	var data PostPageData
 	post, err := dbpool.FindPostWithFilename(filename, user.ID, cfg.Space)
 	if err == nil {
-		parsedText := pkg.ParseText(post.Text)
+		parsedText := cfg.ParseText(post.Text)
 
 		// we need the blog name from the readme unfortunately
 		readme, err := dbpool.FindPostWithFilename("_readme", user.ID, cfg.Space)
 		if err == nil {
-			readmeParsed := pkg.ParseText(readme.Text)
+			readmeParsed := cfg.ParseText(readme.Text)
 			if readmeParsed.MetaData.Title != "" {
 				blogName = readmeParsed.MetaData.Title
 			}
 		}
 
+		// conditional hits are not views so check before counting one
+		if CheckNotModified(w, r, NewestUpdatedAt(post, header, readme), format) {
+			return
+		}
+
 		// validate and fire off analytic event
 		if isRequestTrackable(r) {
 			_, err := dbpool.AddViewCount(post.ID)
@@ -295,22 +557,14 @@ func postHandler(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
-		data = PostPageData{
-			Site:         *cfg.GetSiteData(),
-			PageTitle:    GetPostTitle(post),
-			URL:          template.URL(cfg.PostURL(post.Username, post.Filename)),
-			BlogURL:      template.URL(cfg.BlogURL(username)),
-			Description:  post.Description,
-			ListType:     parsedText.MetaData.ListType,
-			Title:        FilenameToTitle(post.Filename, post.Title),
-			PublishAt:    post.PublishAt.Format("02 Jan, 2006"),
-			PublishAtISO: post.PublishAt.Format(time.RFC3339),
-			Username:     username,
-			BlogName:     blogName,
-			Items:        parsedText.Items,
-		}
+		data = createPostPageData(cfg, post, username, blogName, parsedText)
 	} else {
 		logger.Infof("post not found %s/%s", username, filename)
+		if format != FormatHTML {
+			writeFormatError(w, r, format, http.StatusNotFound, "post not found")
+			return
+		}
+
 		data = PostPageData{
 			Site:         *cfg.GetSiteData(),
 			PageTitle:    "Post not found",
@@ -331,13 +585,162 @@ func postHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	ts, err := renderTemplate([]string{
-		"./html/post.page.tmpl",
-		"./html/list.partial.tmpl",
+	writePostPage(w, r, format, &data)
+}
+
+// writePostPage renders a post in the negotiated format.
+func writePostPage(w http.ResponseWriter, r *http.Request, format string, data *PostPageData) {
+	cfg := GetCfg(r)
+	logger := GetLogger(r)
+
+	if format != FormatHTML {
+		writePostFormat(w, r, format, data)
+		return
+	}
+
+	content, err := render.ToString(&render.HTMLRenderer{Anchors: true}, render.Items(data.Items, data.ListType))
+	if err != nil {
+		logger.Error(err)
+	}
+	data.Content = template.HTML(content)
+
+	ts, err := renderTemplate(cfg, []string{
+		"html/post.page.tmpl",
+	})
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = ts.Execute(w, data)
+	if err != nil {
+		logger.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// rawPostHandler serves the text a post was uploaded with so scripts can
+// consume lists directly.
+func rawPostHandler(w http.ResponseWriter, r *http.Request) {
+	username := GetUsernameFromRequest(r)
+	filename := GetFilenameFromRequest(r)
+	dbpool := GetDB(r)
+	logger := GetLogger(r)
+	cfg := GetCfg(r)
+
+	user, err := dbpool.FindUserForName(username)
+	if err != nil {
+		logger.Infof("blog not found: %s", username)
+		http.Error(w, "blog not found", http.StatusNotFound)
+		return
+	}
+
+	post, err := dbpool.FindPostWithFilename(filename, user.ID, cfg.Space)
+	if err != nil {
+		logger.Infof("post not found %s/%s", username, filename)
+		http.Error(w, "post not found", http.StatusNotFound)
+		return
+	}
+
+	modtime := NewestUpdatedAt(post)
+	if CheckNotModified(w, r, modtime, "raw") {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	http.ServeContent(w, r, fmt.Sprintf("%s.txt", post.Filename), modtime, strings.NewReader(post.Text))
+}
+
+func GetItemFromRequest(r *http.Request) string {
+	subdomain := GetSubdomain(r)
+
+	var id string
+	if subdomain == "" {
+		id, _ = url.PathUnescape(GetField(r, 2))
+	} else {
+		id, _ = url.PathUnescape(GetField(r, 1))
+	}
+
+	return id
+}
+
+// postItemHandler renders a single item of a post, along with its nested
+// items, so it can be shared or embedded on its own.
+func postItemHandler(w http.ResponseWriter, r *http.Request) {
+	username := GetUsernameFromRequest(r)
+	filename := GetFilenameFromRequest(r)
+	id := GetItemFromRequest(r)
+	dbpool := GetDB(r)
+	logger := GetLogger(r)
+	cfg := GetCfg(r)
+
+	setVaryHeader(w)
+	format := NegotiateFormat(r)
+
+	user, err := dbpool.FindUserForName(username)
+	if err != nil {
+		logger.Infof("blog not found: %s", username)
+		writeFormatError(w, r, format, http.StatusNotFound, "blog not found")
+		return
+	}
+
+	post, err := dbpool.FindPostWithFilename(filename, user.ID, cfg.Space)
+	if err != nil {
+		logger.Infof("post not found %s/%s", username, filename)
+		writeFormatError(w, r, format, http.StatusNotFound, "post not found")
+		return
+	}
+
+	parsedText := cfg.ParseText(post.Text)
+	item := parsedText.FindItem(id)
+	if item == nil {
+		logger.Infof("item not found %s/%s/%s", username, filename, id)
+		writeFormatError(w, r, format, http.StatusNotFound, "item not found")
+		return
+	}
+
+	if CheckNotModified(w, r, NewestUpdatedAt(post), fmt.Sprintf("item:%s", format)) {
+		return
+	}
+
+	title := FilenameToTitle(post.Filename, post.Title)
+	data := ItemPageData{
+		Site:        *cfg.GetSiteData(),
+		PageTitle:   fmt.Sprintf("%s -- %s", item.Value, title),
+		URL:         template.URL(cfg.PostItemURL(post.Username, post.Filename, item.ID)),
+		PostURL:     template.URL(fmt.Sprintf("%s#%s", cfg.PostURL(post.Username, post.Filename), url.PathEscape(item.ID))),
+		BlogURL:     template.URL(cfg.BlogURL(username)),
+		Title:       title,
+		Username:    username,
+		BlogName:    FindBlogName(dbpool, cfg, user),
+		Description: item.Value,
+		ListType:    parsedText.MetaData.ListType,
+		Item:        item,
+	}
+
+	parsedItem := render.Items([]*pkg.ListItem{item}, data.ListType)
+	if format == FormatJSON {
+		writeJSON(w, r, http.StatusOK, data)
+		return
+	} else if format != FormatHTML {
+		writeDocument(w, r, format, data.Title, string(data.PostURL), parsedItem)
+		return
+	}
+
+	content, err := render.ToString(&render.HTMLRenderer{}, parsedItem)
+	if err != nil {
+		logger.Error(err)
+	}
+	data.Content = template.HTML(content)
+
+	ts, err := renderTemplate(cfg, []string{
+		"html/item.page.tmpl",
 	})
 
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
 	err = ts.Execute(w, data)
@@ -359,12 +762,9 @@ func transparencyHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ts, err := template.ParseFiles(
-		"./html/transparency.page.tmpl",
-		"./html/footer.partial.tmpl",
-		"./html/marketing-footer.partial.tmpl",
-		"./html/base.layout.tmpl",
-	)
+	ts, err := renderTemplate(cfg, []string{
+		"html/transparency.page.tmpl",
+	})
 
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -386,36 +786,140 @@ func readHandler(w http.ResponseWriter, r *http.Request) {
 	logger := GetLogger(r)
 	cfg := GetCfg(r)
 
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
 	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
-	pager, err := dbpool.FindAllUpdatedPosts(&db.Pager{Num: 30, Page: page}, cfg.Space)
+	pager := &db.Pager{Num: 30, Page: page}
+
+	var posts []*db.Post
+	var total int
+	snippets := map[string]string{}
+	if query != "" {
+		results, err := dbpool.FindPostsBySearch(pager, query, cfg.Space)
+		if err != nil {
+			logger.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for _, result := range results.Data {
+			posts = append(posts, result.Post)
+			snippets[result.ID] = result.Snippet
+		}
+		total = results.Total
+	} else {
+		results, err := dbpool.FindAllUpdatedPosts(pager, cfg.Space)
+		if err != nil {
+			logger.Error(err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		posts = results.Data
+		total = results.Total
+	}
+
+	ts, err := renderTemplate(cfg, []string{
+		"html/read.page.tmpl",
+	})
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+
+	nextPage := ""
+	if page < total-1 {
+		nextPage = PageURL("/read", query, page+1)
+	}
+
+	prevPage := ""
+	if page > 0 {
+		prevPage = PageURL("/read", query, page-1)
+	}
+
+	data := ReadPageData{
+		Site:     *cfg.GetSiteData(),
+		Query:    query,
+		NextPage: nextPage,
+		PrevPage: prevPage,
+	}
+	for _, post := range posts {
+		item := PostItemData{
+			URL:            template.URL(cfg.PostURL(post.Username, post.Filename)),
+			BlogURL:        template.URL(cfg.BlogURL(post.Username)),
+			Title:          FilenameToTitle(post.Filename, post.Title),
+			Description:    post.Description,
+			Username:       post.Username,
+			PublishAt:      post.PublishAt.Format("02 Jan, 2006"),
+			PublishAtISO:   post.PublishAt.Format(time.RFC3339),
+			UpdatedTimeAgo: TimeAgo(post.UpdatedAt),
+			UpdatedAtISO:   post.UpdatedAt.Format(time.RFC3339),
+			Snippet:        SplitSnippet(snippets[post.ID]),
+		}
+		data.Posts = append(data.Posts, item)
+	}
+
+	err = ts.Execute(w, data)
+	if err != nil {
+		logger.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func GetTagFromRequest(r *http.Request) string {
+	subdomain := GetSubdomain(r)
+
+	var tag string
+	if subdomain == "" {
+		tag, _ = url.PathUnescape(GetField(r, 1))
+	} else {
+		tag, _ = url.PathUnescape(GetField(r, 0))
+	}
+
+	return strings.ToLower(tag)
+}
+
+func tagHandler(w http.ResponseWriter, r *http.Request) {
+	dbpool := GetDB(r)
+	logger := GetLogger(r)
+	cfg := GetCfg(r)
+
+	tag, _ := url.PathUnescape(GetField(r, 0))
+	tag = strings.ToLower(tag)
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	pager, err := dbpool.FindPostsByTag(&db.Pager{Num: 30, Page: page}, tag, cfg.Space)
 	if err != nil {
 		logger.Error(err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	ts, err := renderTemplate([]string{
-		"./html/read.page.tmpl",
+	ts, err := renderTemplate(cfg, []string{
+		"html/tag.page.tmpl",
 	})
 
 	if err != nil {
+		logger.Error(err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
+	tagURL := cfg.TagURL(tag)
 	nextPage := ""
 	if page < pager.Total-1 {
-		nextPage = fmt.Sprintf("/read?page=%d", page+1)
+		nextPage = fmt.Sprintf("%s?page=%d", tagURL, page+1)
 	}
 
 	prevPage := ""
 	if page > 0 {
-		prevPage = fmt.Sprintf("/read?page=%d", page-1)
+		prevPage = fmt.Sprintf("%s?page=%d", tagURL, page-1)
 	}
 
-	data := ReadPageData{
-		Site:     *cfg.GetSiteData(),
-		NextPage: nextPage,
-		PrevPage: prevPage,
+	data := TagPageData{
+		Site:      *cfg.GetSiteData(),
+		PageTitle: fmt.Sprintf("#%s -- %s", tag, cfg.Domain),
+		Tag:       tag,
+		URL:       template.URL(tagURL),
+		RSSURL:    template.URL(cfg.RssTagURL(tag)),
+		NextPage:  nextPage,
+		PrevPage:  prevPage,
 	}
 	for _, post := range pager.Data {
 		item := PostItemData{
@@ -439,72 +943,73 @@ func readHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func rssBlogHandler(w http.ResponseWriter, r *http.Request) {
+func blogTagHandler(w http.ResponseWriter, r *http.Request) {
 	username := GetUsernameFromRequest(r)
+	tag := GetTagFromRequest(r)
 	dbpool := GetDB(r)
 	logger := GetLogger(r)
 	cfg := GetCfg(r)
 
 	user, err := dbpool.FindUserForName(username)
 	if err != nil {
-		logger.Infof("rss feed not found: %s", username)
-		http.Error(w, "rss feed not found", http.StatusNotFound)
+		logger.Infof("blog not found: %s", username)
+		http.Error(w, "blog not found", http.StatusNotFound)
 		return
 	}
-	posts, err := dbpool.FindUpdatedPostsForUser(user.ID, cfg.Space)
+	posts, err := dbpool.FindUserPostsByTag(tag, user.ID, cfg.Space)
 	if err != nil {
 		logger.Error(err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, "could not fetch posts for tag", http.StatusInternalServerError)
 		return
 	}
 
-	ts, err := template.ParseFiles("./html/rss.page.tmpl", "./html/list.partial.tmpl")
+	ts, err := renderTemplate(cfg, []string{
+		"html/tag.page.tmpl",
+	})
+
 	if err != nil {
 		logger.Error(err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	headerTxt := &HeaderTxt{
-		Title: GetBlogName(username),
+	data := TagPageData{
+		Site:      *cfg.GetSiteData(),
+		PageTitle: fmt.Sprintf("#%s -- %s", tag, GetBlogName(username)),
+		Tag:       tag,
+		URL:       template.URL(cfg.BlogTagURL(username, tag)),
+		RSSURL:    template.URL(cfg.RssBlogTagURL(username, tag)),
+		BlogURL:   template.URL(cfg.BlogURL(username)),
+		Username:  username,
 	}
-
 	for _, post := range posts {
-		if post.Filename == "_header" {
-			parsedText := pkg.ParseText(post.Text)
-			if parsedText.MetaData.Title != "" {
-				headerTxt.Title = parsedText.MetaData.Title
-			}
-
-			if parsedText.MetaData.Description != "" {
-				headerTxt.Bio = parsedText.MetaData.Description
-			}
-
-			break
+		item := PostItemData{
+			URL:            template.URL(cfg.PostURL(post.Username, post.Filename)),
+			BlogURL:        template.URL(cfg.BlogURL(post.Username)),
+			Title:          FilenameToTitle(post.Filename, post.Title),
+			Description:    post.Description,
+			Username:       post.Username,
+			PublishAt:      post.PublishAt.Format("02 Jan, 2006"),
+			PublishAtISO:   post.PublishAt.Format(time.RFC3339),
+			UpdatedTimeAgo: TimeAgo(post.UpdatedAt),
+			UpdatedAtISO:   post.UpdatedAt.Format(time.RFC3339),
 		}
+		data.Posts = append(data.Posts, item)
 	}
 
-	feed := &feeds.Feed{
-		Title:       headerTxt.Title,
-		Link:        &feeds.Link{Href: cfg.BlogURL(username)},
-		Description: headerTxt.Bio,
-		Author:      &feeds.Author{Name: username},
-		Created:     time.Now(),
+	err = ts.Execute(w, data)
+	if err != nil {
+		logger.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
+}
 
+func createFeedItems(cfg *ConfigSite, posts []*db.Post) []*feeds.Item {
 	var feedItems []*feeds.Item
 	for _, post := range posts {
-		if slices.Contains(HiddenPosts, post.Filename) {
-			continue
-		}
-
-		parsed := pkg.ParseText(post.Text)
-		var tpl bytes.Buffer
-		data := &PostPageData{
-			ListType: parsed.MetaData.ListType,
-			Items:    parsed.Items,
-		}
-		if err := ts.Execute(&tpl, data); err != nil {
+		parsed := cfg.ParseText(post.Text)
+		content, err := render.ToString(&render.HTMLRenderer{Feed: true}, parsed)
+		if err != nil {
 			continue
 		}
 
@@ -512,7 +1017,7 @@ func rssBlogHandler(w http.ResponseWriter, r *http.Request) {
 			Id:      cfg.PostURL(post.Username, post.Filename),
 			Title:   FilenameToTitle(post.Filename, post.Title),
 			Link:    &feeds.Link{Href: cfg.PostURL(post.Username, post.Filename)},
-			Content: tpl.String(),
+			Content: content,
 			Created: *post.PublishAt,
 		}
 
@@ -522,12 +1027,17 @@ func rssBlogHandler(w http.ResponseWriter, r *http.Request) {
 
 		feedItems = append(feedItems, item)
 	}
-	feed.Items = feedItems
+	return feedItems
+}
+
+func writeFeed(w http.ResponseWriter, r *http.Request, feed *feeds.Feed) {
+	logger := GetLogger(r)
 
 	rss, err := feed.ToAtom()
 	if err != nil {
 		logger.Error(err)
 		http.Error(w, "Could not generate atom rss feed", http.StatusInternalServerError)
+		return
 	}
 
 	w.Header().Add("Content-Type", "application/atom+xml")
@@ -537,90 +1047,179 @@ func rssBlogHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func rssHandler(w http.ResponseWriter, r *http.Request) {
+func rssTagHandler(w http.ResponseWriter, r *http.Request) {
 	dbpool := GetDB(r)
 	logger := GetLogger(r)
 	cfg := GetCfg(r)
 
-	pager, err := dbpool.FindAllPosts(&db.Pager{Num: 25, Page: 0}, cfg.Space)
+	tag, _ := url.PathUnescape(GetField(r, 0))
+	tag = strings.ToLower(tag)
+	pager, err := dbpool.FindPostsByTag(&db.Pager{Num: 25, Page: 0}, tag, cfg.Space)
 	if err != nil {
 		logger.Error(err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	ts, err := template.ParseFiles("./html/rss.page.tmpl", "./html/list.partial.tmpl")
+	if CheckNotModified(w, r, NewestUpdatedAt(pager.Data...), fmt.Sprint(len(pager.Data))) {
+		return
+	}
+
+	feed := &feeds.Feed{
+		Title:       fmt.Sprintf("#%s on %s", tag, cfg.Domain),
+		Link:        &feeds.Link{Href: cfg.TagURL(tag)},
+		Description: fmt.Sprintf("%s latest posts tagged #%s", cfg.Domain, tag),
+		Author:      &feeds.Author{Name: cfg.Domain},
+		Created:     time.Now(),
+		Items:       createFeedItems(cfg, pager.Data),
+	}
+
+	writeFeed(w, r, feed)
+}
+
+func rssBlogTagHandler(w http.ResponseWriter, r *http.Request) {
+	username := GetUsernameFromRequest(r)
+	tag := GetTagFromRequest(r)
+	dbpool := GetDB(r)
+	logger := GetLogger(r)
+	cfg := GetCfg(r)
+
+	user, err := dbpool.FindUserForName(username)
+	if err != nil {
+		logger.Infof("rss feed not found: %s", username)
+		http.Error(w, "rss feed not found", http.StatusNotFound)
+		return
+	}
+	posts, err := dbpool.FindUserPostsByTag(tag, user.ID, cfg.Space)
 	if err != nil {
 		logger.Error(err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	if CheckNotModified(w, r, NewestUpdatedAt(posts...), fmt.Sprint(len(posts))) {
+		return
+	}
+
 	feed := &feeds.Feed{
-		Title:       fmt.Sprintf("%s discovery feed", cfg.Domain),
-		Link:        &feeds.Link{Href: cfg.ReadURL()},
-		Description: fmt.Sprintf("%s latest posts", cfg.Domain),
-		Author:      &feeds.Author{Name: cfg.Domain},
+		Title:       fmt.Sprintf("#%s on %s", tag, GetBlogName(username)),
+		Link:        &feeds.Link{Href: cfg.BlogTagURL(username, tag)},
+		Description: fmt.Sprintf("latest posts tagged #%s", tag),
+		Author:      &feeds.Author{Name: username},
 		Created:     time.Now(),
+		Items:       createFeedItems(cfg, posts),
 	}
 
-	var feedItems []*feeds.Item
-	for _, post := range pager.Data {
-		parsed := pkg.ParseText(post.Text)
-		var tpl bytes.Buffer
-		data := &PostPageData{
-			ListType: parsed.MetaData.ListType,
-			Items:    parsed.Items,
-		}
-		if err := ts.Execute(&tpl, data); err != nil {
-			continue
-		}
+	writeFeed(w, r, feed)
+}
 
-		item := &feeds.Item{
-			Id:      cfg.PostURL(post.Username, post.Filename),
-			Title:   post.Title,
-			Link:    &feeds.Link{Href: cfg.PostURL(post.Username, post.Filename)},
-			Content: tpl.String(),
-			Created: *post.PublishAt,
+func rssBlogHandler(w http.ResponseWriter, r *http.Request) {
+	username := GetUsernameFromRequest(r)
+	dbpool := GetDB(r)
+	logger := GetLogger(r)
+	cfg := GetCfg(r)
+
+	user, err := dbpool.FindUserForName(username)
+	if err != nil {
+		logger.Infof("rss feed not found: %s", username)
+		http.Error(w, "rss feed not found", http.StatusNotFound)
+		return
+	}
+	posts, err := dbpool.FindUpdatedPostsForUser(user.ID, cfg.Space)
+	if err != nil {
+		logger.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if CheckNotModified(w, r, NewestUpdatedAt(posts...), fmt.Sprint(len(posts))) {
+		return
+	}
+
+	headerTxt := &HeaderTxt{
+		Title: GetBlogName(username),
+	}
+
+	for _, post := range posts {
+		if post.Filename == "_header" {
+			parsedText := cfg.ParseText(post.Text)
+			if parsedText.MetaData.Title != "" {
+				headerTxt.Title = parsedText.MetaData.Title
+			}
+
+			if parsedText.MetaData.Description != "" {
+				headerTxt.Bio = parsedText.MetaData.Description
+			}
+
+			break
 		}
+	}
 
-		if post.Description != "" {
-			item.Description = post.Description
+	var feedPosts []*db.Post
+	for _, post := range posts {
+		if slices.Contains(HiddenPosts, post.Filename) {
+			continue
 		}
+		feedPosts = append(feedPosts, post)
+	}
 
-		feedItems = append(feedItems, item)
+	feed := &feeds.Feed{
+		Title:       headerTxt.Title,
+		Link:        &feeds.Link{Href: cfg.BlogURL(username)},
+		Description: headerTxt.Bio,
+		Author:      &feeds.Author{Name: username},
+		Created:     time.Now(),
+		Items:       createFeedItems(cfg, feedPosts),
 	}
-	feed.Items = feedItems
 
-	rss, err := feed.ToAtom()
+	writeFeed(w, r, feed)
+}
+
+func rssHandler(w http.ResponseWriter, r *http.Request) {
+	dbpool := GetDB(r)
+	logger := GetLogger(r)
+	cfg := GetCfg(r)
+
+	pager, err := dbpool.FindAllPosts(&db.Pager{Num: 25, Page: 0}, cfg.Space)
 	if err != nil {
 		logger.Error(err)
-		http.Error(w, "Could not generate atom rss feed", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	w.Header().Add("Content-Type", "application/atom+xml")
-	_, err = w.Write([]byte(rss))
-	if err != nil {
-		logger.Error(err)
+	if CheckNotModified(w, r, NewestUpdatedAt(pager.Data...), fmt.Sprint(len(pager.Data))) {
+		return
+	}
+
+	feed := &feeds.Feed{
+		Title:       fmt.Sprintf("%s discovery feed", cfg.Domain),
+		Link:        &feeds.Link{Href: cfg.ReadURL()},
+		Description: fmt.Sprintf("%s latest posts", cfg.Domain),
+		Author:      &feeds.Author{Name: cfg.Domain},
+		Created:     time.Now(),
+		Items:       createFeedItems(cfg, pager.Data),
 	}
+
+	writeFeed(w, r, feed)
 }
 
 func serveFile(file string, contentType string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		logger := GetLogger(r)
+		cfg := GetCfg(r)
 
-		contents, err := ioutil.ReadFile(fmt.Sprintf("./public/%s", file))
+		contents, err := fs.ReadFile(cfg.AssetsFS(), fmt.Sprintf("public/%s", file))
 		if err != nil {
 			logger.Error(err)
 			http.Error(w, "file not found", 404)
+			return
 		}
 
 		w.Header().Add("Content-Type", contentType)
+		w.Header().Set("ETag", fmt.Sprintf(`"%x"`, sha256.Sum256(contents)))
 
-		_, err = w.Write(contents)
-		if err != nil {
-			logger.Error(err)
-		}
+		// handles If-None-Match and Range requests for us
+		http.ServeContent(w, r, file, time.Time{}, bytes.NewReader(contents))
 	}
 }
 
@@ -638,13 +1237,19 @@ func createStaticRoutes() []Route {
 
 func createMainRoutes(staticRoutes []Route) []Route {
 	routes := []Route{
-		NewRoute("GET", "/", createPageHandler("./html/marketing.page.tmpl")),
-		NewRoute("GET", "/spec", createPageHandler("./html/spec.page.tmpl")),
-		NewRoute("GET", "/ops", createPageHandler("./html/ops.page.tmpl")),
-		NewRoute("GET", "/privacy", createPageHandler("./html/privacy.page.tmpl")),
-		NewRoute("GET", "/help", createPageHandler("./html/help.page.tmpl")),
+		NewRoute("GET", "/", createPageHandler("html/marketing.page.tmpl")),
+		NewRoute("GET", "/spec", createPageHandler("html/spec.page.tmpl")),
+		NewRoute("GET", "/ops", createPageHandler("html/ops.page.tmpl")),
+		NewRoute("GET", "/privacy", createPageHandler("html/privacy.page.tmpl")),
+		NewRoute("GET", "/help", createPageHandler("html/help.page.tmpl")),
 		NewRoute("GET", "/transparency", transparencyHandler),
+		NewRoute("GET", "/check", domainCheckHandler),
 		NewRoute("GET", "/read", readHandler),
+		NewRoute("GET", "/api/read", apiReadHandler),
+		NewRoute("GET", "/api/([^/]+)", apiBlogHandler),
+		NewRoute("GET", "/api/([^/]+)/([^/]+)", apiPostHandler),
+		NewRoute("GET", "/tags/([^/]+)", tagHandler),
+		NewRoute("GET", "/tags/([^/]+)/rss", rssTagHandler),
 	}
 
 	routes = append(
@@ -661,6 +1266,14 @@ func createMainRoutes(staticRoutes []Route) []Route {
 
 		NewRoute("GET", "/([^/]+)", blogHandler),
 		NewRoute("GET", "/([^/]+)/rss", rssBlogHandler),
+		NewRoute("GET", "/([^/]+)/changes", changesBlogHandler),
+		NewRoute("GET", "/([^/]+)/tags/([^/]+)", blogTagHandler),
+		NewRoute("GET", "/([^/]+)/tags/([^/]+)/rss", rssBlogTagHandler),
+		NewRoute("GET", "/([^/]+)/([^/]+)\\.txt", rawPostHandler),
+		NewRoute("GET", "/([^/]+)/([^/]+)/changes", changesPostHandler),
+		NewRoute("GET", "/([^/]+)/([^/]+)/items/([^/]+)", postItemHandler),
+		NewRoute("GET", "/([^/]+)/([^/]+)/history", postHistoryHandler),
+		NewRoute("GET", "/([^/]+)/([^/]+)/history/([^/]+)", postRevisionHandler),
 		NewRoute("GET", "/([^/]+)/([^/]+)", postHandler),
 	)
 
@@ -671,6 +1284,11 @@ func createSubdomainRoutes(staticRoutes []Route) []Route {
 	routes := []Route{
 		NewRoute("GET", "/", blogHandler),
 		NewRoute("GET", "/rss", rssBlogHandler),
+		NewRoute("GET", "/changes", changesBlogHandler),
+		NewRoute("GET", "/api", apiBlogHandler),
+		NewRoute("GET", "/api/([^/]+)", apiPostHandler),
+		NewRoute("GET", "/tags/([^/]+)", blogTagHandler),
+		NewRoute("GET", "/tags/([^/]+)/rss", rssBlogTagHandler),
 	}
 
 	routes = append(
@@ -680,6 +1298,12 @@ func createSubdomainRoutes(staticRoutes []Route) []Route {
 
 	routes = append(
 		routes,
+		NewRoute("GET", "/raw/([^/]+)", rawPostHandler),
+		NewRoute("GET", "/([^/]+)\\.txt", rawPostHandler),
+		NewRoute("GET", "/([^/]+)/changes", changesPostHandler),
+		NewRoute("GET", "/([^/]+)/items/([^/]+)", postItemHandler),
+		NewRoute("GET", "/([^/]+)/history", postHistoryHandler),
+		NewRoute("GET", "/([^/]+)/history/([^/]+)", postRevisionHandler),
 		NewRoute("GET", "/([^/]+)", postHandler),
 	)
 
@@ -688,14 +1312,33 @@ func createSubdomainRoutes(staticRoutes []Route) []Route {
 
 func StartApiServer() {
 	cfg := NewConfigSite()
-	db := postgres.NewDB(&cfg.ConfigCms)
+	db := storage.NewDB(&cfg.ConfigCms)
 	defer db.Close()
 	logger := cfg.Logger
 
+	if err := parsePageTemplates(cfg); err != nil {
+		logger.Fatal(err)
+	}
+
 	staticRoutes := createStaticRoutes()
 	mainRoutes := createMainRoutes(staticRoutes)
 	subdomainRoutes := createSubdomainRoutes(staticRoutes)
 
+	for _, route := range mainRoutes {
+		if err := CheckReservedRoute(route.regex.String()); err != nil {
+			logger.Fatal(err)
+		}
+		if err := CheckReservedFilenameRoute(route.regex.String(), false); err != nil {
+			logger.Fatal(err)
+		}
+	}
+
+	for _, route := range subdomainRoutes {
+		if err := CheckReservedFilenameRoute(route.regex.String(), true); err != nil {
+			logger.Fatal(err)
+		}
+	}
+
 	handler := CreateServe(mainRoutes, subdomainRoutes, cfg, db, logger)
 	router := http.HandlerFunc(handler)
 
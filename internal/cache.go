@@ -0,0 +1,78 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"git.sr.ht/~erock/wish/cms/db"
+)
+
+var CacheControl = "public, max-age=300"
+
+// startedAt is mixed into every etag so a deploy with new templates does not
+// keep serving stale pages to clients holding an old etag.
+var startedAt = time.Now()
+
+// NewestUpdatedAt returns the most recent update time of the posts so pages
+// built from many posts change whenever any of them does.
+func NewestUpdatedAt(posts ...*db.Post) time.Time {
+	newest := time.Time{}
+	for _, post := range posts {
+		if post == nil || post.UpdatedAt == nil {
+			continue
+		}
+		if post.UpdatedAt.After(newest) {
+			newest = *post.UpdatedAt
+		}
+	}
+	return newest
+}
+
+func createETag(modtime time.Time, variant string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d:%d:%s", startedAt.UnixNano(), modtime.UnixNano(), variant)))
+	return fmt.Sprintf(`W/"%x"`, sum[:16])
+}
+
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckNotModified sets the cache headers for a response last changed at
+// modtime and answers with a 304 when the client already has it.  The
+// variant distinguishes responses that share a modtime, like different
+// formats of the same post.  Handlers must return right away when this
+// reports true.
+func CheckNotModified(w http.ResponseWriter, r *http.Request, modtime time.Time, variant string) bool {
+	etag := createETag(modtime, variant)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", CacheControl)
+	if !modtime.IsZero() {
+		w.Header().Set("Last-Modified", modtime.UTC().Format(http.TimeFormat))
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	notModified := false
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		notModified = etagMatches(match, etag)
+	} else if since := r.Header.Get("If-Modified-Since"); since != "" && !modtime.IsZero() {
+		t, err := http.ParseTime(since)
+		notModified = err == nil && !modtime.Truncate(time.Second).After(t)
+	}
+
+	if notModified {
+		w.WriteHeader(http.StatusNotModified)
+	}
+	return notModified
+}
@@ -0,0 +1,189 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"git.sr.ht/~erock/lists.sh/internal/storage"
+	"git.sr.ht/~erock/lists.sh/pkg"
+	"git.sr.ht/~erock/lists.sh/pkg/render"
+	"github.com/gorilla/feeds"
+	"golang.org/x/exp/slices"
+)
+
+// ChangesLimit is how many uploads the change feeds look at.
+var ChangesLimit = 50
+
+func pluralItems(count int) string {
+	if count == 1 {
+		return "1 item"
+	}
+	return fmt.Sprintf("%d items", count)
+}
+
+// ChangeSummary describes a diff, e.g. "3 items added, 1 removed".
+func ChangeSummary(added, removed int) string {
+	if added > 0 && removed > 0 {
+		return fmt.Sprintf("%s added, %d removed", pluralItems(added), removed)
+	}
+	if removed > 0 {
+		return fmt.Sprintf("%s removed", pluralItems(removed))
+	}
+	return fmt.Sprintf("%s added", pluralItems(added))
+}
+
+// diffSection flattens the items of a diff with the given op under a
+// heading.  Nested items are diffed on their own so their children are
+// dropped to avoid listing them twice.
+func diffSection(heading string, diff []*pkg.DiffItem, op string) []*pkg.ListItem {
+	items := []*pkg.ListItem{}
+	for _, d := range diff {
+		if d.Op != op {
+			continue
+		}
+		item := *d.Item
+		item.Depth = 0
+		item.Children = nil
+		items = append(items, &item)
+	}
+
+	if len(items) == 0 {
+		return items
+	}
+	return append([]*pkg.ListItem{{Value: heading, IsHeaderTwo: true}}, items...)
+}
+
+// createChangeFeedItems turns every upload that added or removed items
+// into a feed entry that only shows those items.
+func createChangeFeedItems(cfg *ConfigSite, changes []*storage.Change, withTitle bool) []*feeds.Item {
+	var feedItems []*feeds.Item
+	for _, change := range changes {
+		diff := pkg.DiffItems(cfg.ParseText(change.PreviousText).Items, cfg.ParseText(change.Text).Items)
+		added, removed := pkg.DiffStats(diff)
+		if added == 0 && removed == 0 {
+			continue
+		}
+
+		items := diffSection("added", diff, pkg.DiffAdded)
+		items = append(items, diffSection("removed", diff, pkg.DiffRemoved)...)
+		content, err := render.ToString(&render.HTMLRenderer{Feed: true}, render.Items(items, "disc"))
+		if err != nil {
+			continue
+		}
+
+		title := ChangeSummary(added, removed)
+		if withTitle {
+			title = fmt.Sprintf("%s: %s", FilenameToTitle(change.Filename, change.Title), title)
+		}
+
+		url := cfg.PostRevisionURL(change.Username, change.Filename, change.ID)
+		feedItems = append(feedItems, &feeds.Item{
+			Id:      url,
+			Title:   title,
+			Link:    &feeds.Link{Href: url},
+			Content: content,
+			Created: *change.CreatedAt,
+		})
+	}
+	return feedItems
+}
+
+func newestChange(changes []*storage.Change) time.Time {
+	if len(changes) == 0 {
+		return time.Time{}
+	}
+	return *changes[0].CreatedAt
+}
+
+// changesPostHandler is a feed of the items added and removed from a post.
+func changesPostHandler(w http.ResponseWriter, r *http.Request) {
+	username := GetUsernameFromRequest(r)
+	filename := GetFilenameFromRequest(r)
+	dbpool := GetDB(r)
+	logger := GetLogger(r)
+	cfg := GetCfg(r)
+
+	user, err := dbpool.FindUserForName(username)
+	if err != nil {
+		logger.Infof("changes feed not found: %s", username)
+		http.Error(w, "changes feed not found", http.StatusNotFound)
+		return
+	}
+
+	post, err := dbpool.FindPostWithFilename(filename, user.ID, cfg.Space)
+	if err != nil {
+		logger.Infof("changes feed not found: %s/%s", username, filename)
+		http.Error(w, "changes feed not found", http.StatusNotFound)
+		return
+	}
+
+	changes, err := dbpool.FindChangesForPost(post.ID, ChangesLimit)
+	if err != nil {
+		logger.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if CheckNotModified(w, r, newestChange(changes), fmt.Sprint(len(changes))) {
+		return
+	}
+
+	title := FilenameToTitle(post.Filename, post.Title)
+	feed := &feeds.Feed{
+		Title:       fmt.Sprintf("changes to %s", title),
+		Link:        &feeds.Link{Href: cfg.PostURL(username, post.Filename)},
+		Description: fmt.Sprintf("items added to and removed from %s", title),
+		Author:      &feeds.Author{Name: username},
+		Created:     time.Now(),
+		Items:       createChangeFeedItems(cfg, changes, false),
+	}
+
+	writeFeed(w, r, feed)
+}
+
+// changesBlogHandler is a feed of the items added and removed from every
+// post on a blog.
+func changesBlogHandler(w http.ResponseWriter, r *http.Request) {
+	username := GetUsernameFromRequest(r)
+	dbpool := GetDB(r)
+	logger := GetLogger(r)
+	cfg := GetCfg(r)
+
+	user, err := dbpool.FindUserForName(username)
+	if err != nil {
+		logger.Infof("changes feed not found: %s", username)
+		http.Error(w, "changes feed not found", http.StatusNotFound)
+		return
+	}
+
+	changes, err := dbpool.FindChangesForUser(user.ID, ChangesLimit, cfg.Space)
+	if err != nil {
+		logger.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if CheckNotModified(w, r, newestChange(changes), fmt.Sprint(len(changes))) {
+		return
+	}
+
+	visible := []*storage.Change{}
+	for _, change := range changes {
+		if !slices.Contains(HiddenPosts, change.Filename) {
+			visible = append(visible, change)
+		}
+	}
+
+	blogName := FindBlogName(dbpool, cfg, user)
+	feed := &feeds.Feed{
+		Title:       fmt.Sprintf("changes to %s", blogName),
+		Link:        &feeds.Link{Href: cfg.BlogURL(username)},
+		Description: fmt.Sprintf("items added to and removed from the lists on %s", blogName),
+		Author:      &feeds.Author{Name: username},
+		Created:     time.Now(),
+		Items:       createChangeFeedItems(cfg, visible, true),
+	}
+
+	writeFeed(w, r, feed)
+}
@@ -0,0 +1,254 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"git.sr.ht/~erock/wish/cms/db"
+	"github.com/charmbracelet/wish"
+	"github.com/gliderlabs/ssh"
+	"golang.org/x/exp/slices"
+)
+
+// Command is run with `ssh <domain> <name> [args]`.
+type Command struct {
+	Name  string
+	Usage string
+	Help  string
+	Run   func(h *DbHandler, s ssh.Session, user *db.User, args []string) (string, error)
+}
+
+// Commands are every ssh command besides scp, which has its own middleware.
+var Commands = []*Command{
+	{
+		Name:  "ls",
+		Usage: "ls",
+		Help:  "list your posts",
+		Run: func(h *DbHandler, s ssh.Session, user *db.User, args []string) (string, error) {
+			return h.List(user)
+		},
+	},
+	{
+		Name:  "cat",
+		Usage: "cat <post>",
+		Help:  "print the source of a post",
+		Run: func(h *DbHandler, s ssh.Session, user *db.User, args []string) (string, error) {
+			return h.Cat(user, args)
+		},
+	},
+	{
+		Name:  "rm",
+		Usage: "rm <post>...",
+		Help:  "delete posts",
+		Run: func(h *DbHandler, s ssh.Session, user *db.User, args []string) (string, error) {
+			return h.Remove(user, args)
+		},
+	},
+	{
+		Name:  "post",
+		Usage: "post <name> [--hidden] [--publish-at YYYY-MM-DD]",
+		Help:  "publish the text sent over stdin as <name>.txt",
+		Run: func(h *DbHandler, s ssh.Session, user *db.User, args []string) (string, error) {
+			return h.Publish(s, user, args)
+		},
+	},
+	{
+		Name:  "sync",
+		Usage: "sync [--prune]",
+		Help:  "compare the .txt files listed on stdin with your posts, --prune removes posts missing locally",
+		Run: func(h *DbHandler, s ssh.Session, user *db.User, args []string) (string, error) {
+			return h.Sync(s, user, args)
+		},
+	},
+	{
+		Name:  "stats",
+		Usage: "stats",
+		Help:  "show how many times your posts were viewed",
+		Run: func(h *DbHandler, s ssh.Session, user *db.User, args []string) (string, error) {
+			return h.Stats(user)
+		},
+	},
+	{
+		Name:  "restore",
+		Usage: "restore <post> [revision]",
+		Help:  "list the revisions of a post or restore one",
+		Run: func(h *DbHandler, s ssh.Session, user *db.User, args []string) (string, error) {
+			return h.Restore(user, args)
+		},
+	},
+	{
+		Name:  "domain",
+		Usage: "domain [<domain> | rm]",
+		Help:  "show, set or remove the custom domain for your blog",
+		Run: func(h *DbHandler, s ssh.Session, user *db.User, args []string) (string, error) {
+			return h.Domain(user, args)
+		},
+	},
+}
+
+func FindCommand(name string) *Command {
+	idx := slices.IndexFunc(Commands, func(cmd *Command) bool {
+		return cmd.Name == name
+	})
+	if idx == -1 {
+		return nil
+	}
+	return Commands[idx]
+}
+
+// CommandHelp lists every command with what it does.
+func CommandHelp(cfg *ConfigSite) string {
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "usage: ssh %s <command>\n\n", cfg.Domain)
+	for _, cmd := range Commands {
+		fmt.Fprintf(tw, "  %s\t%s\n", cmd.Usage, cmd.Help)
+	}
+	fmt.Fprintf(tw, "  help\tshow this message\n")
+	_ = tw.Flush()
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// writeTable aligns rows of tab separated columns.
+func writeTable(rows [][]string) string {
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	_ = tw.Flush()
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// List shows every post, including hidden and scheduled ones.
+func (h *DbHandler) List(user *db.User) (string, error) {
+	posts, err := h.DBPool.FindAllPostsForUser(user.ID, h.Cfg.Space)
+	if err != nil {
+		return "", err
+	}
+
+	if len(posts) == 0 {
+		return fmt.Sprintf("no posts yet, publish one with: scp post.txt %s:", h.Cfg.Domain), nil
+	}
+
+	rows := [][]string{{"post", "published", "updated", "url"}}
+	for _, post := range posts {
+		updatedAt := ""
+		if post.UpdatedAt != nil {
+			updatedAt = post.UpdatedAt.Format("2006-01-02 15:04")
+		}
+		rows = append(rows, []string{
+			post.Filename,
+			post.PublishAt.Format("2006-01-02"),
+			updatedAt,
+			h.Cfg.PostURL(user.Name, post.Filename),
+		})
+	}
+
+	return writeTable(rows), nil
+}
+
+// Cat returns the text a post was uploaded with.
+func (h *DbHandler) Cat(user *db.User, args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("usage: ssh %s cat <post>", h.Cfg.Domain)
+	}
+
+	filename := SanitizeFileExt(args[0])
+	post, err := h.DBPool.FindPostWithFilename(filename, user.ID, h.Cfg.Space)
+	if err != nil {
+		return "", fmt.Errorf("post %s not found", filename)
+	}
+
+	return post.Text, nil
+}
+
+// Remove deletes posts.  Every post is looked up first so a typo does not
+// leave only some of them deleted.
+func (h *DbHandler) Remove(user *db.User, args []string) (string, error) {
+	if len(args) == 0 {
+		return "", fmt.Errorf("usage: ssh %s rm <post>...", h.Cfg.Domain)
+	}
+
+	postIDs := []string{}
+	filenames := []string{}
+	for _, arg := range args {
+		filename := SanitizeFileExt(arg)
+		post, err := h.DBPool.FindPostWithFilename(filename, user.ID, h.Cfg.Space)
+		if err != nil {
+			return "", fmt.Errorf("post %s not found, nothing was removed", filename)
+		}
+		postIDs = append(postIDs, post.ID)
+		filenames = append(filenames, filename)
+	}
+
+	err := h.DBPool.RemovePosts(postIDs)
+	if err != nil {
+		return "", err
+	}
+
+	for _, filename := range filenames {
+		h.Cfg.Logger.Infof("(%s) removed over ssh", filename)
+	}
+	return fmt.Sprintf("removed %s", strings.Join(filenames, ", ")), nil
+}
+
+// Stats shows the view count of every post.
+func (h *DbHandler) Stats(user *db.User) (string, error) {
+	posts, err := h.DBPool.FindPostViewsForUser(user.ID, h.Cfg.Space)
+	if err != nil {
+		return "", err
+	}
+
+	total := 0
+	rows := [][]string{{"post", "views"}}
+	for _, post := range posts {
+		total += post.Views
+		rows = append(rows, []string{post.Filename, fmt.Sprint(post.Views)})
+	}
+	rows = append(rows, []string{"total", fmt.Sprint(total)})
+
+	return writeTable(rows), nil
+}
+
+// CommandMiddleware runs every command other than scp and the cms.
+func CommandMiddleware(h *DbHandler) wish.Middleware {
+	return func(sh ssh.Handler) ssh.Handler {
+		return func(s ssh.Session) {
+			args := s.Command()
+			if args[0] == "help" {
+				wish.Println(s, CommandHelp(h.Cfg))
+				sh(s)
+				return
+			}
+
+			cmd := FindCommand(args[0])
+			if cmd == nil {
+				wish.Fatalln(s, fmt.Sprintf("unknown command %q\n\n%s", args[0], CommandHelp(h.Cfg)))
+				return
+			}
+
+			// sessions run concurrently so the user is not kept on h
+			user, err := h.FindSessionUser(s)
+			if err != nil {
+				wish.Fatalln(s, err)
+				return
+			}
+
+			msg, err := cmd.Run(h, s, user, args[1:])
+			if err != nil {
+				wish.Fatalln(s, err)
+				return
+			}
+
+			if strings.HasSuffix(msg, "\n") {
+				wish.Print(s, msg)
+			} else {
+				wish.Println(s, msg)
+			}
+			sh(s)
+		}
+	}
+}
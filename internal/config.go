@@ -3,23 +3,57 @@ package internal
 import (
 	"fmt"
 	"html/template"
+	"io/fs"
 	"log"
+	"net"
 	"net/url"
+	"os"
+	"strings"
 
+	lists "git.sr.ht/~erock/lists.sh"
+	"git.sr.ht/~erock/lists.sh/pkg"
 	"git.sr.ht/~erock/wish/cms/config"
 	"go.uber.org/zap"
 )
 
 type SitePageData struct {
-	Domain  template.URL
-	HomeURL template.URL
-	Email   string
+	Domain            template.URL
+	HomeURL           template.URL
+	Email             string
+	URLSchemes        []string
+	ReservedFilenames []string
 }
 
 type ConfigSite struct {
 	config.ConfigCms
 	config.ConfigURL
 	SubdomainsEnabled bool
+	AllowedURLSchemes []string
+	Dev               bool
+	Resolver          TXTResolver
+	DomainAliases     []string
+}
+
+// withApplicationName names the app on every connection so the database can
+// tell which app a signup came from, reserved usernames only apply to the
+// app they are reserved for.
+func withApplicationName(dbURL string, name string) string {
+	if dbURL == "" || strings.Contains(dbURL, "application_name=") {
+		return dbURL
+	}
+
+	if strings.HasPrefix(dbURL, "postgres://") || strings.HasPrefix(dbURL, "postgresql://") {
+		u, err := url.Parse(dbURL)
+		if err != nil {
+			return dbURL
+		}
+		query := u.Query()
+		query.Set("application_name", name)
+		u.RawQuery = query.Encode()
+		return u.String()
+	}
+
+	return fmt.Sprintf("%s application_name=%s", dbURL, name)
 }
 
 func NewConfigSite() *ConfigSite {
@@ -29,6 +63,10 @@ func NewConfigSite() *ConfigSite {
 	port := GetEnv("LISTS_WEB_PORT", "3000")
 	protocol := GetEnv("LISTS_PROTOCOL", "https")
 	dbURL := GetEnv("DATABASE_URL", "")
+	schemes := GetEnv("LISTS_URL_SCHEMES", strings.Join(pkg.AllowedURLSchemes, ","))
+	dev := GetEnv("LISTS_DEV", "0")
+	aliases := GetEnv("LISTS_DOMAIN_ALIASES", "")
+	space := "lists"
 	subdomainsEnabled := false
 	if subdomains == "1" {
 		subdomainsEnabled = true
@@ -40,30 +78,66 @@ func NewConfigSite() *ConfigSite {
 	intro += "Finally, send your list files to us:\n\n"
 	intro += fmt.Sprintf("scp ~/blog/*.txt %s:/\n\n", domain)
 
+	allowedSchemes := []string{}
+	for _, scheme := range strings.Split(schemes, ",") {
+		scheme = strings.ToLower(strings.TrimSpace(scheme))
+		if scheme != "" {
+			allowedSchemes = append(allowedSchemes, scheme)
+		}
+	}
+	domainAliases := []string{}
+	for _, alias := range strings.Split(aliases, ",") {
+		alias = strings.ToLower(strings.TrimSpace(alias))
+		if alias != "" {
+			domainAliases = append(domainAliases, alias)
+		}
+	}
+
 	return &ConfigSite{
 		SubdomainsEnabled: subdomainsEnabled,
+		AllowedURLSchemes: allowedSchemes,
+		Dev:               dev == "1",
+		Resolver:          net.DefaultResolver,
+		DomainAliases:     domainAliases,
 		ConfigCms: config.ConfigCms{
 			Domain:      domain,
 			Email:       email,
 			Port:        port,
 			Protocol:    protocol,
-			DbURL:       dbURL,
+			DbURL:       withApplicationName(dbURL, space),
 			Description: "A microblog for your lists.",
 			IntroText:   intro,
-			Space:       "lists",
+			Space:       space,
 			Logger:      CreateLogger(),
 		},
 	}
 }
 
+// AssetsFS returns the templates and public files compiled into the binary
+// or, in dev mode, the ones in the working directory so edits show up
+// without a rebuild.
+func (c *ConfigSite) AssetsFS() fs.FS {
+	if c.Dev {
+		return os.DirFS(".")
+	}
+	return lists.Assets
+}
+
 func (c *ConfigSite) GetSiteData() *SitePageData {
 	return &SitePageData{
-		Domain:  template.URL(c.Domain),
-		HomeURL: template.URL(c.HomeURL()),
-		Email:   c.Email,
+		Domain:            template.URL(c.Domain),
+		HomeURL:           template.URL(c.HomeURL()),
+		Email:             c.Email,
+		URLSchemes:        c.AllowedURLSchemes,
+		ReservedFilenames: ReservedFilenames,
 	}
 }
 
+// ParseText parses a post with the url schemes allowed on this site.
+func (c *ConfigSite) ParseText(text string) *pkg.ParsedText {
+	return pkg.ParseTextWithOptions(text, &pkg.ParseOptions{URLSchemes: c.AllowedURLSchemes})
+}
+
 func (c *ConfigSite) BlogURL(username string) string {
 	if c.IsSubdomains() {
 		return fmt.Sprintf("%s://%s.%s", c.Protocol, username, c.Domain)
@@ -81,6 +155,26 @@ func (c *ConfigSite) PostURL(username, filename string) string {
 	return fmt.Sprintf("/%s/%s", username, fname)
 }
 
+func (c *ConfigSite) RawPostURL(username, filename string) string {
+	return fmt.Sprintf("%s.txt", c.PostURL(username, filename))
+}
+
+func (c *ConfigSite) PostHistoryURL(username, filename string) string {
+	return fmt.Sprintf("%s/history", c.PostURL(username, filename))
+}
+
+func (c *ConfigSite) PostRevisionURL(username, filename, revisionID string) string {
+	return fmt.Sprintf("%s/%s", c.PostHistoryURL(username, filename), url.PathEscape(revisionID))
+}
+
+func (c *ConfigSite) PostItemURL(username, filename, id string) string {
+	return fmt.Sprintf("%s/items/%s", c.PostURL(username, filename), url.PathEscape(id))
+}
+
+func (c *ConfigSite) PostChangesURL(username, filename string) string {
+	return fmt.Sprintf("%s/changes", c.PostURL(username, filename))
+}
+
 func (c *ConfigSite) IsSubdomains() bool {
 	return c.SubdomainsEnabled
 }
@@ -93,6 +187,36 @@ func (c *ConfigSite) RssBlogURL(username string) string {
 	return fmt.Sprintf("/%s/rss", username)
 }
 
+func (c *ConfigSite) BlogChangesURL(username string) string {
+	return fmt.Sprintf("%s/changes", c.BlogURL(username))
+}
+
+func (c *ConfigSite) TagURL(tag string) string {
+	tname := url.PathEscape(tag)
+	if c.IsSubdomains() {
+		return fmt.Sprintf("%s://%s/tags/%s", c.Protocol, c.Domain, tname)
+	}
+
+	return fmt.Sprintf("/tags/%s", tname)
+}
+
+func (c *ConfigSite) RssTagURL(tag string) string {
+	return fmt.Sprintf("%s/rss", c.TagURL(tag))
+}
+
+func (c *ConfigSite) BlogTagURL(username, tag string) string {
+	tname := url.PathEscape(tag)
+	if c.IsSubdomains() {
+		return fmt.Sprintf("%s://%s.%s/tags/%s", c.Protocol, username, c.Domain, tname)
+	}
+
+	return fmt.Sprintf("/%s/tags/%s", username, tname)
+}
+
+func (c *ConfigSite) RssBlogTagURL(username, tag string) string {
+	return fmt.Sprintf("%s/rss", c.BlogTagURL(username, tag))
+}
+
 func (c *ConfigSite) HomeURL() string {
 	if c.IsSubdomains() {
 		return fmt.Sprintf("%s://%s", c.Protocol, c.Domain)
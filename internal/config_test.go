@@ -0,0 +1,35 @@
+package internal
+
+import "testing"
+
+func TestWithApplicationName(t *testing.T) {
+	cases := []struct {
+		dbURL string
+		want  string
+	}{
+		{dbURL: "", want: ""},
+		{
+			dbURL: "postgres://postgres:secret@db:5432/lists?sslmode=disable",
+			want:  "postgres://postgres:secret@db:5432/lists?application_name=lists&sslmode=disable",
+		},
+		{
+			dbURL: "postgresql://db/lists",
+			want:  "postgresql://db/lists?application_name=lists",
+		},
+		{
+			dbURL: "host=db dbname=lists sslmode=disable",
+			want:  "host=db dbname=lists sslmode=disable application_name=lists",
+		},
+		{
+			dbURL: "postgres://db/lists?application_name=other",
+			want:  "postgres://db/lists?application_name=other",
+		},
+	}
+
+	for _, tc := range cases {
+		got := withApplicationName(tc.dbURL, "lists")
+		if got != tc.want {
+			t.Errorf("got %q, want %q", got, tc.want)
+		}
+	}
+}
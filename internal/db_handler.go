@@ -5,6 +5,7 @@ import (
 	"io"
 	"time"
 
+	"git.sr.ht/~erock/lists.sh/internal/storage"
 	"git.sr.ht/~erock/lists.sh/pkg"
 	"git.sr.ht/~erock/wish/cms/db"
 	"git.sr.ht/~erock/wish/cms/util"
@@ -15,50 +16,72 @@ import (
 
 var HiddenPosts = []string{"_readme", "_header"}
 
-type Opener struct {
-	entry *sendutils.FileEntry
-}
+// ReservedFilenames can never be posts because they are routes on every
+// blog.  The web and gemini routers check that every fixed route is listed
+// here.
+var ReservedFilenames = []string{"api", "changes", "raw", "rss", "search", "tags"}
 
-func (o *Opener) Open(name string) (io.Reader, error) {
-	return o.entry.Reader, nil
+func IsReservedFilename(filename string) bool {
+	return slices.Contains(ReservedFilenames, filename)
 }
 
 type DbHandler struct {
-	User   *db.User
-	DBPool db.DB
+	DBPool storage.DB
 	Cfg    *ConfigSite
 }
 
-func NewDbHandler(dbpool db.DB, cfg *ConfigSite) *DbHandler {
+func NewDbHandler(dbpool storage.DB, cfg *ConfigSite) *DbHandler {
 	return &DbHandler{
 		DBPool: dbpool,
 		Cfg:    cfg,
 	}
 }
 
-func (h *DbHandler) Validate(s ssh.Session) error {
-	var err error
+// FindSessionUser looks up the user by the key of a session.  The handler is
+// shared by every session so the user is passed along instead of kept on it.
+func (h *DbHandler) FindSessionUser(s ssh.Session) (*db.User, error) {
 	key, err := util.KeyText(s)
 	if err != nil {
-		return fmt.Errorf("key not found")
+		return nil, fmt.Errorf("key not found")
 	}
 
 	user, err := h.DBPool.FindUserForKey(s.User(), key)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if user.Name == "" {
-		return fmt.Errorf("must have username set")
+		return nil, fmt.Errorf("must have username set")
 	}
 
-	h.User = user
-	return nil
+	return user, nil
+}
+
+func (h *DbHandler) Validate(s ssh.Session) error {
+	_, err := h.FindSessionUser(s)
+	return err
+}
+
+// WriteOptions override what the text of a post sets, they come from the
+// flags of the `post` command.
+type WriteOptions struct {
+	Hidden    bool
+	PublishAt *time.Time
 }
 
 func (h *DbHandler) Write(s ssh.Session, entry *sendutils.FileEntry) (string, error) {
+	user, err := h.FindSessionUser(s)
+	if err != nil {
+		return "", err
+	}
+	return h.WritePost(user, entry, &WriteOptions{})
+}
+
+// WritePost validates, parses and saves a post.  Every upload goes through
+// here, whether it came from scp or stdin.
+func (h *DbHandler) WritePost(user *db.User, entry *sendutils.FileEntry, opts *WriteOptions) (string, error) {
 	logger := h.Cfg.Logger
-	userID := h.User.ID
+	userID := user.ID
 	filename := SanitizeFileExt(entry.Name)
 	title := filename
 
@@ -67,11 +90,6 @@ func (h *DbHandler) Write(s ssh.Session, entry *sendutils.FileEntry) (string, er
 		logger.Debug("unable to load post, continuing:", err)
 	}
 
-	user, err := h.DBPool.FindUser(userID)
-	if err != nil {
-		return "", fmt.Errorf("error for %s: %v", filename, err)
-	}
-
 	var text string
 	if b, err := io.ReadAll(entry.Reader); err == nil {
 		text = string(b)
@@ -81,11 +99,22 @@ func (h *DbHandler) Write(s ssh.Session, entry *sendutils.FileEntry) (string, er
 		return "", fmt.Errorf("WARNING: (%s) invalid file, format must be '.txt' and the contents must be plain text, skipping", entry.Name)
 	}
 
-	parsedText := pkg.ParseText(text)
+	// an empty file still removes a post that was uploaded before the name
+	// was reserved
+	if len(text) > 0 && IsReservedFilename(filename) {
+		return "", fmt.Errorf("WARNING: (%s) the name %q is used by a page on your blog, rename the file, skipping", entry.Name, filename)
+	}
+
+	parsedText := h.Cfg.ParseText(text)
+	for _, diag := range parsedText.Diagnostics {
+		logger.Infof("(%s) %s", filename, diag)
+	}
+
 	if parsedText.MetaData.Title != "" {
 		title = parsedText.MetaData.Title
 	}
 	description := parsedText.MetaData.Description
+	postData := CreatePostData(parsedText)
 
 	// if the file is empty we remove it from our database
 	if len(text) == 0 {
@@ -105,10 +134,28 @@ func (h *DbHandler) Write(s ssh.Session, entry *sendutils.FileEntry) (string, er
 		if parsedText.MetaData.PublishAt != nil {
 			publishAt = *parsedText.MetaData.PublishAt
 		}
-		hidden := slices.Contains(HiddenPosts, filename)
+		if opts.PublishAt != nil {
+			publishAt = *opts.PublishAt
+		}
+		hidden := slices.Contains(HiddenPosts, filename) || opts.Hidden
 
 		logger.Infof("(%s) not found, adding record", filename)
-		_, err = h.DBPool.InsertPost(userID, filename, title, text, description, &publishAt, hidden, h.Cfg.Space)
+		newPost, err := h.DBPool.InsertPost(userID, filename, title, text, description, &publishAt, hidden, h.Cfg.Space)
+		if err != nil {
+			return "", fmt.Errorf("error for %s: %v", filename, err)
+		}
+
+		_, err = h.DBPool.InsertRevision(newPost.ID, title, text)
+		if err != nil {
+			return "", fmt.Errorf("error for %s: %v", filename, err)
+		}
+
+		err = h.DBPool.UpdatePostData(newPost.ID, postData)
+		if err != nil {
+			return "", fmt.Errorf("error for %s: %v", filename, err)
+		}
+
+		err = h.DBPool.ReplaceTagsForPost(postData.Tags, newPost.ID)
 		if err != nil {
 			return "", fmt.Errorf("error for %s: %v", filename, err)
 		}
@@ -117,9 +164,20 @@ func (h *DbHandler) Write(s ssh.Session, entry *sendutils.FileEntry) (string, er
 		if parsedText.MetaData.PublishAt != nil {
 			publishAt = parsedText.MetaData.PublishAt
 		}
-		if text == post.Text {
+		if opts.PublishAt != nil {
+			publishAt = opts.PublishAt
+		}
+
+		if opts.Hidden {
+			err = h.DBPool.SetPostHidden(post.ID, true)
+			if err != nil {
+				return "", fmt.Errorf("error for %s: %v", filename, err)
+			}
+		}
+
+		if text == post.Text && opts.PublishAt == nil {
 			logger.Infof("(%s) found, but text is identical, skipping", filename)
-			return h.Cfg.PostURL(user.Name, filename), nil
+			return WithWarnings(h.Cfg.PostURL(user.Name, filename), entry.Name, parsedText.Diagnostics), nil
 		}
 
 		logger.Infof("(%s) found, updating record", filename)
@@ -127,7 +185,37 @@ func (h *DbHandler) Write(s ssh.Session, entry *sendutils.FileEntry) (string, er
 		if err != nil {
 			return "", fmt.Errorf("error for %s: %v", filename, err)
 		}
+
+		// only a new publish date was given so there is nothing to keep
+		if text != post.Text {
+			_, err = h.DBPool.InsertRevision(post.ID, title, text)
+			if err != nil {
+				return "", fmt.Errorf("error for %s: %v", filename, err)
+			}
+		}
+
+		err = h.DBPool.UpdatePostData(post.ID, postData)
+		if err != nil {
+			return "", fmt.Errorf("error for %s: %v", filename, err)
+		}
+
+		err = h.DBPool.ReplaceTagsForPost(postData.Tags, post.ID)
+		if err != nil {
+			return "", fmt.Errorf("error for %s: %v", filename, err)
+		}
 	}
 
-	return h.Cfg.PostURL(user.Name, filename), nil
+	return WithWarnings(h.Cfg.PostURL(user.Name, filename), entry.Name, parsedText.Diagnostics), nil
+}
+
+// WithWarnings appends parser diagnostics to the message sent back to the
+// client so mistakes are caught at upload time.
+func WithWarnings(msg string, name string, diagnostics []*pkg.Diagnostic) string {
+	for _, diag := range diagnostics {
+		msg += fmt.Sprintf(
+			"\nWARNING: (%s:%d:%d) %s: %s",
+			name, diag.Line, diag.Column, diag.Severity, diag.Message,
+		)
+	}
+	return msg
 }
@@ -0,0 +1,153 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"git.sr.ht/~erock/lists.sh/internal/storage"
+	"git.sr.ht/~erock/wish/cms/db"
+)
+
+// TXTResolver looks up the TXT records for a name.  net.DefaultResolver
+// satisfies it; tests can swap in a fake.
+type TXTResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+var domainRe = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// NormalizeDomain lowercases a domain and makes sure it is a hostname we
+// could serve a blog from.
+func NormalizeDomain(cfg *ConfigSite, domain string) (string, error) {
+	domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+	if len(domain) > 253 || !domainRe.MatchString(domain) {
+		return "", fmt.Errorf("%q is not a valid domain", domain)
+	}
+
+	for _, appDomain := range cfg.Domains() {
+		if domain == appDomain || strings.HasSuffix(domain, fmt.Sprintf(".%s", appDomain)) {
+			return "", fmt.Errorf("%q belongs to %s", domain, appDomain)
+		}
+	}
+
+	return domain, nil
+}
+
+// DomainTXTRecord is the name of the TXT record that must contain the
+// username to prove the user owns the domain.
+func DomainTXTRecord(domain string) string {
+	return fmt.Sprintf("_lists.%s", domain)
+}
+
+// VerifyDomainOwner checks the domain's TXT record for the username.
+func VerifyDomainOwner(resolver TXTResolver, domain, username string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	name := DomainTXTRecord(domain)
+	records, err := resolver.LookupTXT(ctx, name)
+	if err != nil {
+		return fmt.Errorf("could not find TXT record %s: %v", name, err)
+	}
+
+	for _, record := range records {
+		if strings.TrimSpace(strings.ToLower(record)) == username {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("TXT record %s does not contain %q", name, username)
+}
+
+func (h *DbHandler) domainInstructions(user *db.User, domain *storage.Domain) string {
+	appDomain := strings.Split(h.Cfg.Domain, ":")[0]
+	msg := fmt.Sprintf("%s is not verified yet, add these DNS records:\n\n", domain.Domain)
+	msg += fmt.Sprintf("  CNAME %s %s\n", domain.Domain, appDomain)
+	msg += fmt.Sprintf("  TXT   %s %q\n\n", DomainTXTRecord(domain.Domain), user.Name)
+	msg += fmt.Sprintf("then run: ssh %s domain", appDomain)
+	return msg
+}
+
+func (h *DbHandler) verifyDomain(user *db.User, domain *storage.Domain) (string, error) {
+	err := VerifyDomainOwner(h.Cfg.Resolver, domain.Domain, user.Name)
+	if err != nil {
+		return fmt.Sprintf("%v\n\n%s", err, h.domainInstructions(user, domain)), nil
+	}
+
+	// another blog might have verified the domain first
+	err = h.DBPool.VerifyDomain(domain.ID)
+	if err != nil {
+		h.Cfg.Logger.Error(err)
+		return "", fmt.Errorf("could not verify %s, it might already be in use", domain.Domain)
+	}
+
+	return fmt.Sprintf("%s is verified and now serves your blog", domain.Domain), nil
+}
+
+// Domain manages the custom domain for the user:
+//
+//	domain            show the domain and retry verification
+//	domain <domain>   set the domain
+//	domain rm         remove the domain
+func (h *DbHandler) Domain(user *db.User, args []string) (string, error) {
+	userID := user.ID
+
+	if len(args) == 0 {
+		domain, err := h.DBPool.FindDomainForUser(userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Sprintf("no custom domain set, add one with: ssh %s domain <domain>", h.Cfg.Domain), nil
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if domain.VerifiedAt != nil {
+			return fmt.Sprintf("%s is verified and serves your blog", domain.Domain), nil
+		}
+		return h.verifyDomain(user, domain)
+	}
+
+	if args[0] == "rm" {
+		err := h.DBPool.RemoveDomainForUser(userID)
+		if err != nil {
+			return "", err
+		}
+		return "custom domain removed", nil
+	}
+
+	name, err := NormalizeDomain(h.Cfg, args[0])
+	if err != nil {
+		return "", err
+	}
+
+	domain, err := h.DBPool.SetDomainForUser(userID, name)
+	if err != nil {
+		h.Cfg.Logger.Error(err)
+		return "", fmt.Errorf("could not set domain %s", name)
+	}
+
+	return h.verifyDomain(user, domain)
+}
+
+// domainCheckHandler lets caddy know if it should issue a certificate for a
+// domain with on demand tls.
+func domainCheckHandler(w http.ResponseWriter, r *http.Request) {
+	dbpool := GetDB(r)
+	logger := GetLogger(r)
+
+	domain := strings.ToLower(r.URL.Query().Get("domain"))
+	_, err := dbpool.FindUserForDomain(domain)
+	if err != nil {
+		logger.Infof("domain not verified: %s", domain)
+		http.Error(w, "domain not verified", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,117 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"git.sr.ht/~erock/wish/cms/config"
+)
+
+type fakeResolver struct {
+	records map[string][]string
+	err     error
+}
+
+func (r *fakeResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	return r.records[name], nil
+}
+
+func TestVerifyDomainOwner(t *testing.T) {
+	cases := []struct {
+		name     string
+		resolver *fakeResolver
+		ok       bool
+	}{
+		{
+			name:     "match",
+			resolver: &fakeResolver{records: map[string][]string{"_lists.example.com": {"erock"}}},
+			ok:       true,
+		},
+		{
+			name:     "one of many records",
+			resolver: &fakeResolver{records: map[string][]string{"_lists.example.com": {"v=spf1 -all", "erock"}}},
+			ok:       true,
+		},
+		{
+			name:     "case and whitespace",
+			resolver: &fakeResolver{records: map[string][]string{"_lists.example.com": {"  ERock \n"}}},
+			ok:       true,
+		},
+		{
+			name:     "mismatch",
+			resolver: &fakeResolver{records: map[string][]string{"_lists.example.com": {"someone"}}},
+			ok:       false,
+		},
+		{
+			name:     "record on the wrong name",
+			resolver: &fakeResolver{records: map[string][]string{"example.com": {"erock"}}},
+			ok:       false,
+		},
+		{
+			name:     "lookup error",
+			resolver: &fakeResolver{err: errors.New("no such host")},
+			ok:       false,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := VerifyDomainOwner(tc.resolver, "example.com", "erock")
+			if tc.ok && err != nil {
+				t.Errorf("expected the domain to verify, got %v", err)
+			}
+			if !tc.ok && err == nil {
+				t.Error("expected the domain not to verify")
+			}
+		})
+	}
+}
+
+func TestNormalizeDomain(t *testing.T) {
+	cfg := &ConfigSite{
+		ConfigCms:     config.ConfigCms{Domain: "lists.sh:443"},
+		DomainAliases: []string{"lists.example"},
+	}
+
+	cases := []struct {
+		domain string
+		want   string
+		ok     bool
+	}{
+		{domain: "example.com", want: "example.com", ok: true},
+		{domain: "  Blog.Example.COM. ", want: "blog.example.com", ok: true},
+		{domain: "xn--bcher-kva.example", want: "xn--bcher-kva.example", ok: true},
+		{domain: "lists.sh", ok: false},
+		{domain: "erock.lists.sh", ok: false},
+		{domain: "a.b.lists.sh", ok: false},
+		{domain: "lists.example", ok: false},
+		{domain: "erock.lists.example", ok: false},
+		{domain: "notlists.sh", want: "notlists.sh", ok: true},
+		{domain: "localhost", ok: false},
+		{domain: "example.com:8080", ok: false},
+		{domain: "-bad.example.com", ok: false},
+		{domain: "", ok: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.domain, func(t *testing.T) {
+			got, err := NormalizeDomain(cfg, tc.domain)
+			if !tc.ok {
+				if err == nil {
+					t.Errorf("expected %q to be rejected, got %q", tc.domain, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected %q to be accepted, got %v", tc.domain, err)
+			}
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
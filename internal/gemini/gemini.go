@@ -1,15 +1,16 @@
 package gemini
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	html "html/template"
+	"io/fs"
 	"net/url"
 	"os"
 	"os/signal"
 	"strconv"
 	"strings"
+	"sync"
 	"text/template"
 	"time"
 
@@ -17,34 +18,73 @@ import (
 	"git.sr.ht/~adnano/go-gemini/certificate"
 	feeds "git.sr.ht/~aw/gorilla-feeds"
 	"git.sr.ht/~erock/lists.sh/internal"
+	"git.sr.ht/~erock/lists.sh/internal/storage"
 	"git.sr.ht/~erock/lists.sh/pkg"
+	"git.sr.ht/~erock/lists.sh/pkg/render"
 	"git.sr.ht/~erock/wish/cms/db"
-	"git.sr.ht/~erock/wish/cms/db/postgres"
 	"golang.org/x/exp/slices"
 )
 
-func renderTemplate(templates []string) (*template.Template, error) {
+var templateCache = map[string]*template.Template{}
+var templateMu sync.Mutex
+
+// renderTemplate parses a page along with the shared partials.  Parsed
+// templates are cached unless we are in dev mode.
+func renderTemplate(cfg *internal.ConfigSite, templates []string) (*template.Template, error) {
+	key := strings.Join(templates, ",")
+	if !cfg.Dev {
+		templateMu.Lock()
+		ts, ok := templateCache[key]
+		templateMu.Unlock()
+		if ok {
+			return ts, nil
+		}
+	}
+
 	files := make([]string, len(templates))
 	copy(files, templates)
 	files = append(
 		files,
-		"./gmi/footer.partial.tmpl",
-		"./gmi/marketing-footer.partial.tmpl",
-		"./gmi/base.layout.tmpl",
+		"gmi/footer.partial.tmpl",
+		"gmi/marketing-footer.partial.tmpl",
+		"gmi/base.layout.tmpl",
 	)
 
-	ts, err := template.ParseFiles(files...)
+	ts, err := template.ParseFS(cfg.AssetsFS(), files...)
 	if err != nil {
 		return nil, err
 	}
+
+	if !cfg.Dev {
+		templateMu.Lock()
+		templateCache[key] = ts
+		templateMu.Unlock()
+	}
 	return ts, nil
 }
 
+// parsePageTemplates parses every page up front so a broken template stops
+// the server from starting.
+func parsePageTemplates(cfg *internal.ConfigSite) error {
+	pages, err := fs.Glob(cfg.AssetsFS(), "gmi/*.page.tmpl")
+	if err != nil {
+		return err
+	}
+
+	for _, page := range pages {
+		_, err := renderTemplate(cfg, []string{page})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func createPageHandler(fname string) gemini.HandlerFunc {
 	return func(ctx context.Context, w gemini.ResponseWriter, r *gemini.Request) {
 		logger := GetLogger(ctx)
 		cfg := GetCfg(ctx)
-		ts, err := renderTemplate([]string{fname})
+		ts, err := renderTemplate(cfg, []string{fname})
 
 		if err != nil {
 			logger.Error(err)
@@ -82,9 +122,8 @@ func blogHandler(ctx context.Context, w gemini.ResponseWriter, r *gemini.Request
 		return
 	}
 
-	ts, err := renderTemplate([]string{
-		"./gmi/blog.page.tmpl",
-		"./gmi/list.partial.tmpl",
+	ts, err := renderTemplate(cfg, []string{
+		"gmi/blog.page.tmpl",
 	})
 
 	if err != nil {
@@ -99,10 +138,11 @@ func blogHandler(ctx context.Context, w gemini.ResponseWriter, r *gemini.Request
 	}
 	readmeTxt := &internal.ReadmeTxt{}
 
+	tags := internal.FindPostTags(dbpool, logger, posts)
 	postCollection := make([]internal.PostItemData, 0, len(posts))
 	for _, post := range posts {
 		if post.Filename == "_header" {
-			parsedText := pkg.ParseText(post.Text)
+			parsedText := cfg.ParseText(post.Text)
 			if parsedText.MetaData.Title != "" {
 				headerTxt.Title = parsedText.MetaData.Title
 			}
@@ -116,12 +156,18 @@ func blogHandler(ctx context.Context, w gemini.ResponseWriter, r *gemini.Request
 				headerTxt.HasItems = true
 			}
 		} else if post.Filename == "_readme" {
-			parsedText := pkg.ParseText(post.Text)
+			parsedText := cfg.ParseText(post.Text)
 			readmeTxt.Items = parsedText.Items
 			readmeTxt.ListType = parsedText.MetaData.ListType
 			if len(readmeTxt.Items) > 0 {
 				readmeTxt.HasItems = true
 			}
+
+			content, err := render.ToString(&render.GemtextRenderer{}, parsedText)
+			if err != nil {
+				logger.Error(err)
+			}
+			readmeTxt.Content = html.HTML(content)
 		} else {
 			p := internal.PostItemData{
 				URL:            html.URL(cfg.PostURL(post.Username, post.Filename)),
@@ -131,6 +177,7 @@ func blogHandler(ctx context.Context, w gemini.ResponseWriter, r *gemini.Request
 				PublishAtISO:   post.PublishAt.Format(time.RFC3339),
 				UpdatedTimeAgo: internal.TimeAgo(post.UpdatedAt),
 				UpdatedAtISO:   post.UpdatedAt.Format(time.RFC3339),
+				Tags:           internal.CreateTagData(cfg, post.Username, tags[post.ID]),
 			}
 			postCollection = append(postCollection, p)
 		}
@@ -145,6 +192,7 @@ func blogHandler(ctx context.Context, w gemini.ResponseWriter, r *gemini.Request
 		Header:    headerTxt,
 		Username:  username,
 		Posts:     postCollection,
+		SearchURL: fmt.Sprintf("/%s/search", username),
 	}
 
 	err = ts.Execute(w, data)
@@ -167,8 +215,8 @@ func readHandler(ctx context.Context, w gemini.ResponseWriter, r *gemini.Request
 		return
 	}
 
-	ts, err := renderTemplate([]string{
-		"./gmi/read.page.tmpl",
+	ts, err := renderTemplate(cfg, []string{
+		"gmi/read.page.tmpl",
 	})
 
 	if err != nil {
@@ -187,9 +235,10 @@ func readHandler(ctx context.Context, w gemini.ResponseWriter, r *gemini.Request
 	}
 
 	data := internal.ReadPageData{
-		Site:     *cfg.GetSiteData(),
-		NextPage: nextPage,
-		PrevPage: prevPage,
+		Site:      *cfg.GetSiteData(),
+		SearchURL: "/search",
+		NextPage:  nextPage,
+		PrevPage:  prevPage,
 	}
 
 	longest := 0
@@ -242,7 +291,7 @@ func postHandler(ctx context.Context, w gemini.ResponseWriter, r *gemini.Request
 	header, _ := dbpool.FindPostWithFilename("_header", user.ID, cfg.Space)
 	blogName := internal.GetBlogName(username)
 	if header != nil {
-		headerParsed := pkg.ParseText(header.Text)
+		headerParsed := cfg.ParseText(header.Text)
 		if headerParsed.MetaData.Title != "" {
 			blogName = headerParsed.MetaData.Title
 		}
@@ -255,12 +304,13 @@ func postHandler(ctx context.Context, w gemini.ResponseWriter, r *gemini.Request
 		return
 	}
 
-	parsedText := pkg.ParseText(post.Text)
+	parsedText := cfg.ParseText(post.Text)
+	todosDone, todosTotal := parsedText.TodoProgress()
 
 	// we need the blog name from the readme unfortunately
 	readme, err := dbpool.FindPostWithFilename("_readme", user.ID, cfg.Space)
 	if err == nil {
-		readmeParsed := pkg.ParseText(readme.Text)
+		readmeParsed := cfg.ParseText(readme.Text)
 		if readmeParsed.MetaData.Title != "" {
 			blogName = readmeParsed.MetaData.Title
 		}
@@ -271,6 +321,11 @@ func postHandler(ctx context.Context, w gemini.ResponseWriter, r *gemini.Request
 		logger.Error(err)
 	}
 
+	content, err := render.ToString(&render.GemtextRenderer{}, parsedText)
+	if err != nil {
+		logger.Error(err)
+	}
+
 	data := internal.PostPageData{
 		Site:         *cfg.GetSiteData(),
 		PageTitle:    internal.GetPostTitle(post),
@@ -284,11 +339,16 @@ func postHandler(ctx context.Context, w gemini.ResponseWriter, r *gemini.Request
 		Username:     username,
 		BlogName:     blogName,
 		Items:        parsedText.Items,
+		Toc:          internal.CreateTocData(cfg, post.Username, post.Filename, parsedText.Toc()),
+		Content:      html.HTML(content),
+		TodosDone:    todosDone,
+		TodosTotal:   todosTotal,
+		Tags:         internal.CreateTagData(cfg, username, parsedText.MetaData.Tags),
+		Extra:        parsedText.MetaData.Extra,
 	}
 
-	ts, err := renderTemplate([]string{
-		"./gmi/post.page.tmpl",
-		"./gmi/list.partial.tmpl",
+	ts, err := renderTemplate(cfg, []string{
+		"gmi/post.page.tmpl",
 	})
 
 	if err != nil {
@@ -303,34 +363,139 @@ func postHandler(ctx context.Context, w gemini.ResponseWriter, r *gemini.Request
 	}
 }
 
-func transparencyHandler(ctx context.Context, w gemini.ResponseWriter, r *gemini.Request) {
+// postItemHandler renders a single item of a post along with its nested
+// items, the table of contents links here since gemini has no anchors.
+func postItemHandler(ctx context.Context, w gemini.ResponseWriter, r *gemini.Request) {
+	username := GetField(ctx, 0)
+	filename, _ := url.PathUnescape(GetField(ctx, 1))
+	id, _ := url.PathUnescape(GetField(ctx, 2))
 	dbpool := GetDB(ctx)
 	logger := GetLogger(ctx)
 	cfg := GetCfg(ctx)
 
-	analytics, err := dbpool.FindSiteAnalytics(cfg.Space)
+	user, err := dbpool.FindUserForName(username)
+	if err != nil {
+		logger.Infof("blog not found: %s", username)
+		w.WriteHeader(gemini.StatusNotFound, "blog not found")
+		return
+	}
+
+	post, err := dbpool.FindPostWithFilename(filename, user.ID, cfg.Space)
+	if err != nil {
+		logger.Infof("post not found %s/%s", username, filename)
+		w.WriteHeader(gemini.StatusNotFound, "post not found")
+		return
+	}
+
+	parsedText := cfg.ParseText(post.Text)
+	item := parsedText.FindItem(id)
+	if item == nil {
+		logger.Infof("item not found %s/%s/%s", username, filename, id)
+		w.WriteHeader(gemini.StatusNotFound, "item not found")
+		return
+	}
+
+	listType := parsedText.MetaData.ListType
+	content, err := render.ToString(&render.GemtextRenderer{}, render.Items([]*pkg.ListItem{item}, listType))
 	if err != nil {
 		logger.Error(err)
+	}
+
+	data := internal.ItemPageData{
+		Site:        *cfg.GetSiteData(),
+		PageTitle:   fmt.Sprintf("%s -- %s", item.Value, internal.FilenameToTitle(post.Filename, post.Title)),
+		URL:         html.URL(cfg.PostItemURL(post.Username, post.Filename, item.ID)),
+		PostURL:     html.URL(cfg.PostURL(post.Username, post.Filename)),
+		BlogURL:     html.URL(cfg.BlogURL(username)),
+		Title:       internal.FilenameToTitle(post.Filename, post.Title),
+		Username:    username,
+		BlogName:    internal.FindBlogName(dbpool, cfg, user),
+		Description: item.Value,
+		ListType:    listType,
+		Item:        item,
+		Content:     html.HTML(content),
+	}
+
+	ts, err := renderTemplate(cfg, []string{
+		"gmi/item.page.tmpl",
+	})
+
+	if err != nil {
 		w.WriteHeader(gemini.StatusTemporaryFailure, err.Error())
 		return
 	}
 
-	ts, err := template.ParseFiles(
-		"./gmi/transparency.page.tmpl",
-		"./gmi/footer.partial.tmpl",
-		"./gmi/marketing-footer.partial.tmpl",
-		"./gmi/base.layout.tmpl",
-	)
+	err = ts.Execute(w, data)
+	if err != nil {
+		logger.Error(err)
+		w.WriteHeader(gemini.StatusTemporaryFailure, err.Error())
+	}
+}
+
+// getSearchQuery prompts the client for a query when the request does not
+// carry one yet.
+func getSearchQuery(w gemini.ResponseWriter, r *gemini.Request, prompt string) (string, bool) {
+	query, err := url.QueryUnescape(r.URL.RawQuery)
+	query = strings.TrimSpace(query)
+	if err != nil || query == "" {
+		w.WriteHeader(gemini.StatusInput, prompt)
+		return "", false
+	}
+	return query, true
+}
+
+// searchPageURL links to a page of search results.  The query string holds
+// the search input so the page is kept in the path.
+func searchPageURL(searchURL string, query string, page int) string {
+	return fmt.Sprintf("%s/%d?%s", searchURL, page, url.QueryEscape(query))
+}
+
+func renderSearch(ctx context.Context, w gemini.ResponseWriter, searchURL string, query string, page int, pager *db.Paginate[*storage.SearchResult]) {
+	logger := GetLogger(ctx)
+	cfg := GetCfg(ctx)
+
+	ts, err := renderTemplate(cfg, []string{
+		"gmi/search.page.tmpl",
+	})
 
 	if err != nil {
 		w.WriteHeader(gemini.StatusTemporaryFailure, err.Error())
 		return
 	}
 
-	data := internal.TransparencyPageData{
+	nextPage := ""
+	if page < pager.Total-1 {
+		nextPage = searchPageURL(searchURL, query, page+1)
+	}
+
+	prevPage := ""
+	if page > 0 {
+		prevPage = searchPageURL(searchURL, query, page-1)
+	}
+
+	data := internal.ReadPageData{
 		Site:      *cfg.GetSiteData(),
-		Analytics: analytics,
+		Query:     query,
+		SearchURL: searchURL,
+		NextPage:  nextPage,
+		PrevPage:  prevPage,
+	}
+	for _, result := range pager.Data {
+		item := internal.PostItemData{
+			URL:            html.URL(cfg.PostURL(result.Username, result.Filename)),
+			BlogURL:        html.URL(cfg.BlogURL(result.Username)),
+			Title:          internal.FilenameToTitle(result.Filename, result.Title),
+			Description:    result.Description,
+			Username:       result.Username,
+			PublishAt:      result.PublishAt.Format("02 Jan, 2006"),
+			PublishAtISO:   result.PublishAt.Format(time.RFC3339),
+			UpdatedTimeAgo: internal.TimeAgo(result.UpdatedAt),
+			UpdatedAtISO:   result.UpdatedAt.Format(time.RFC3339),
+			Snippet:        internal.SplitSnippet(result.Snippet),
+		}
+		data.Posts = append(data.Posts, item)
 	}
+
 	err = ts.Execute(w, data)
 	if err != nil {
 		logger.Error(err)
@@ -338,71 +503,215 @@ func transparencyHandler(ctx context.Context, w gemini.ResponseWriter, r *gemini
 	}
 }
 
-func rssBlogHandler(ctx context.Context, w gemini.ResponseWriter, r *gemini.Request) {
+func searchHandler(ctx context.Context, w gemini.ResponseWriter, r *gemini.Request) {
+	page, _ := strconv.Atoi(GetField(ctx, 0))
+	dbpool := GetDB(ctx)
+	logger := GetLogger(ctx)
+	cfg := GetCfg(ctx)
+
+	query, ok := getSearchQuery(w, r, "search all lists")
+	if !ok {
+		return
+	}
+
+	pager, err := dbpool.FindPostsBySearch(&db.Pager{Num: 30, Page: page}, query, cfg.Space)
+	if err != nil {
+		logger.Error(err)
+		w.WriteHeader(gemini.StatusTemporaryFailure, err.Error())
+		return
+	}
+
+	renderSearch(ctx, w, "/search", query, page, pager)
+}
+
+func blogSearchHandler(ctx context.Context, w gemini.ResponseWriter, r *gemini.Request) {
 	username := GetField(ctx, 0)
+	page, _ := strconv.Atoi(GetField(ctx, 1))
 	dbpool := GetDB(ctx)
 	logger := GetLogger(ctx)
 	cfg := GetCfg(ctx)
 
 	user, err := dbpool.FindUserForName(username)
 	if err != nil {
-		logger.Infof("rss feed not found: %s", username)
-		w.WriteHeader(gemini.StatusNotFound, "rss feed not found")
+		logger.Infof("blog not found: %s", username)
+		w.WriteHeader(gemini.StatusNotFound, "blog not found")
 		return
 	}
-	posts, err := dbpool.FindUpdatedPostsForUser(user.ID, cfg.Space)
+
+	query, ok := getSearchQuery(w, r, fmt.Sprintf("search %s", internal.GetBlogName(username)))
+	if !ok {
+		return
+	}
+
+	pager, err := dbpool.FindUserPostsBySearch(&db.Pager{Num: 30, Page: page}, query, user.ID, cfg.Space)
 	if err != nil {
 		logger.Error(err)
 		w.WriteHeader(gemini.StatusTemporaryFailure, err.Error())
 		return
 	}
 
-	ts, err := template.ParseFiles("./gmi/rss.page.tmpl", "./gmi/list.partial.tmpl")
+	renderSearch(ctx, w, fmt.Sprintf("/%s/search", username), query, page, pager)
+}
+
+func transparencyHandler(ctx context.Context, w gemini.ResponseWriter, r *gemini.Request) {
+	dbpool := GetDB(ctx)
+	logger := GetLogger(ctx)
+	cfg := GetCfg(ctx)
+
+	analytics, err := dbpool.FindSiteAnalytics(cfg.Space)
 	if err != nil {
 		logger.Error(err)
 		w.WriteHeader(gemini.StatusTemporaryFailure, err.Error())
 		return
 	}
 
-	headerTxt := &internal.HeaderTxt{
-		Title: internal.GetBlogName(username),
+	ts, err := renderTemplate(cfg, []string{
+		"gmi/transparency.page.tmpl",
+	})
+
+	if err != nil {
+		w.WriteHeader(gemini.StatusTemporaryFailure, err.Error())
+		return
 	}
 
-	for _, post := range posts {
-		if post.Filename == "_header" {
-			parsedText := pkg.ParseText(post.Text)
-			if parsedText.MetaData.Title != "" {
-				headerTxt.Title = parsedText.MetaData.Title
-			}
+	data := internal.TransparencyPageData{
+		Site:      *cfg.GetSiteData(),
+		Analytics: analytics,
+	}
+	err = ts.Execute(w, data)
+	if err != nil {
+		logger.Error(err)
+		w.WriteHeader(gemini.StatusTemporaryFailure, err.Error())
+	}
+}
 
-			if parsedText.MetaData.Description != "" {
-				headerTxt.Bio = parsedText.MetaData.Description
-			}
+func tagHandler(ctx context.Context, w gemini.ResponseWriter, r *gemini.Request) {
+	dbpool := GetDB(ctx)
+	logger := GetLogger(ctx)
+	cfg := GetCfg(ctx)
 
-			break
+	tag, _ := url.PathUnescape(GetField(ctx, 0))
+	tag = strings.ToLower(tag)
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	pager, err := dbpool.FindPostsByTag(&db.Pager{Num: 30, Page: page}, tag, cfg.Space)
+	if err != nil {
+		logger.Error(err)
+		w.WriteHeader(gemini.StatusTemporaryFailure, err.Error())
+		return
+	}
+
+	ts, err := renderTemplate(cfg, []string{
+		"gmi/tag.page.tmpl",
+	})
+
+	if err != nil {
+		w.WriteHeader(gemini.StatusTemporaryFailure, err.Error())
+		return
+	}
+
+	tagURL := cfg.TagURL(tag)
+	nextPage := ""
+	if page < pager.Total-1 {
+		nextPage = fmt.Sprintf("%s?page=%d", tagURL, page+1)
+	}
+
+	prevPage := ""
+	if page > 0 {
+		prevPage = fmt.Sprintf("%s?page=%d", tagURL, page-1)
+	}
+
+	data := internal.TagPageData{
+		Site:     *cfg.GetSiteData(),
+		Tag:      tag,
+		URL:      html.URL(tagURL),
+		RSSURL:   html.URL(cfg.RssTagURL(tag)),
+		NextPage: nextPage,
+		PrevPage: prevPage,
+	}
+	for _, post := range pager.Data {
+		item := internal.PostItemData{
+			URL:          html.URL(cfg.PostURL(post.Username, post.Filename)),
+			BlogURL:      html.URL(cfg.BlogURL(post.Username)),
+			Title:        internal.FilenameToTitle(post.Filename, post.Title),
+			Description:  post.Description,
+			Username:     post.Username,
+			PublishAt:    post.PublishAt.Format("02 Jan, 2006"),
+			PublishAtISO: post.PublishAt.Format(time.RFC3339),
 		}
+		data.Posts = append(data.Posts, item)
 	}
 
-	feed := &feeds.Feed{
-		Title:       headerTxt.Title,
-		Link:        &feeds.Link{Href: cfg.BlogURL(username)},
-		Description: headerTxt.Bio,
-		Author:      &feeds.Author{Name: username},
-		Created:     time.Now(),
+	err = ts.Execute(w, data)
+	if err != nil {
+		logger.Error(err)
+		w.WriteHeader(gemini.StatusTemporaryFailure, err.Error())
 	}
+}
 
-	var feedItems []*feeds.Item
+func blogTagHandler(ctx context.Context, w gemini.ResponseWriter, r *gemini.Request) {
+	username := GetField(ctx, 0)
+	tag, _ := url.PathUnescape(GetField(ctx, 1))
+	tag = strings.ToLower(tag)
+	dbpool := GetDB(ctx)
+	logger := GetLogger(ctx)
+	cfg := GetCfg(ctx)
+
+	user, err := dbpool.FindUserForName(username)
+	if err != nil {
+		logger.Infof("blog not found: %s", username)
+		w.WriteHeader(gemini.StatusNotFound, "blog not found")
+		return
+	}
+	posts, err := dbpool.FindUserPostsByTag(tag, user.ID, cfg.Space)
+	if err != nil {
+		logger.Error(err)
+		w.WriteHeader(gemini.StatusTemporaryFailure, "could not fetch posts for tag")
+		return
+	}
+
+	ts, err := renderTemplate(cfg, []string{
+		"gmi/tag.page.tmpl",
+	})
+
+	if err != nil {
+		w.WriteHeader(gemini.StatusTemporaryFailure, err.Error())
+		return
+	}
+
+	data := internal.TagPageData{
+		Site:     *cfg.GetSiteData(),
+		Tag:      tag,
+		URL:      html.URL(cfg.BlogTagURL(username, tag)),
+		RSSURL:   html.URL(cfg.RssBlogTagURL(username, tag)),
+		BlogURL:  html.URL(cfg.BlogURL(username)),
+		Username: username,
+	}
 	for _, post := range posts {
-		if slices.Contains(internal.HiddenPosts, post.Filename) {
-			continue
-		}
-		parsed := pkg.ParseText(post.Text)
-		var tpl bytes.Buffer
-		data := &internal.PostPageData{
-			ListType: parsed.MetaData.ListType,
-			Items:    parsed.Items,
+		item := internal.PostItemData{
+			URL:          html.URL(cfg.PostURL(post.Username, post.Filename)),
+			BlogURL:      html.URL(cfg.BlogURL(post.Username)),
+			Title:        internal.FilenameToTitle(post.Filename, post.Title),
+			Description:  post.Description,
+			Username:     post.Username,
+			PublishAt:    post.PublishAt.Format("02 Jan, 2006"),
+			PublishAtISO: post.PublishAt.Format(time.RFC3339),
 		}
-		if err := ts.Execute(&tpl, data); err != nil {
+		data.Posts = append(data.Posts, item)
+	}
+
+	err = ts.Execute(w, data)
+	if err != nil {
+		logger.Error(err)
+		w.WriteHeader(gemini.StatusTemporaryFailure, err.Error())
+	}
+}
+
+func createFeedItems(cfg *internal.ConfigSite, posts []*db.Post) []*feeds.Item {
+	var feedItems []*feeds.Item
+	for _, post := range posts {
+		parsed := cfg.ParseText(post.Text)
+		content, err := render.ToString(&render.GemtextRenderer{}, parsed)
+		if err != nil {
 			continue
 		}
 
@@ -410,7 +719,7 @@ func rssBlogHandler(ctx context.Context, w gemini.ResponseWriter, r *gemini.Requ
 			Id:      cfg.PostURL(post.Username, post.Filename),
 			Title:   internal.FilenameToTitle(post.Filename, post.Title),
 			Link:    &feeds.Link{Href: cfg.PostURL(post.Username, post.Filename)},
-			Content: tpl.String(),
+			Content: content,
 			Created: *post.PublishAt,
 		}
 
@@ -420,7 +729,11 @@ func rssBlogHandler(ctx context.Context, w gemini.ResponseWriter, r *gemini.Requ
 
 		feedItems = append(feedItems, item)
 	}
-	feed.Items = feedItems
+	return feedItems
+}
+
+func writeFeed(ctx context.Context, w gemini.ResponseWriter, feed *feeds.Feed) {
+	logger := GetLogger(ctx)
 
 	rss, err := feed.ToAtom()
 	if err != nil {
@@ -429,26 +742,53 @@ func rssBlogHandler(ctx context.Context, w gemini.ResponseWriter, r *gemini.Requ
 		return
 	}
 
-	// w.Header().Add("Content-Type", "application/atom+xml")
 	_, err = w.Write([]byte(rss))
 	if err != nil {
 		logger.Error(err)
 	}
 }
 
-func rssHandler(ctx context.Context, w gemini.ResponseWriter, r *gemini.Request) {
+func rssTagHandler(ctx context.Context, w gemini.ResponseWriter, r *gemini.Request) {
 	dbpool := GetDB(ctx)
 	logger := GetLogger(ctx)
 	cfg := GetCfg(ctx)
 
-	pager, err := dbpool.FindAllPosts(&db.Pager{Num: 25, Page: 0}, cfg.Space)
+	tag, _ := url.PathUnescape(GetField(ctx, 0))
+	tag = strings.ToLower(tag)
+	pager, err := dbpool.FindPostsByTag(&db.Pager{Num: 25, Page: 0}, tag, cfg.Space)
 	if err != nil {
 		logger.Error(err)
 		w.WriteHeader(gemini.StatusTemporaryFailure, err.Error())
 		return
 	}
 
-	ts, err := template.ParseFiles("./gmi/rss.page.tmpl", "./gmi/list.partial.tmpl")
+	feed := &feeds.Feed{
+		Title:       fmt.Sprintf("#%s on %s", tag, cfg.Domain),
+		Link:        &feeds.Link{Href: cfg.TagURL(tag)},
+		Description: fmt.Sprintf("%s latest posts tagged #%s", cfg.Domain, tag),
+		Author:      &feeds.Author{Name: cfg.Domain},
+		Created:     time.Now(),
+		Items:       createFeedItems(cfg, pager.Data),
+	}
+
+	writeFeed(ctx, w, feed)
+}
+
+func rssBlogTagHandler(ctx context.Context, w gemini.ResponseWriter, r *gemini.Request) {
+	username := GetField(ctx, 0)
+	tag, _ := url.PathUnescape(GetField(ctx, 1))
+	tag = strings.ToLower(tag)
+	dbpool := GetDB(ctx)
+	logger := GetLogger(ctx)
+	cfg := GetCfg(ctx)
+
+	user, err := dbpool.FindUserForName(username)
+	if err != nil {
+		logger.Infof("rss feed not found: %s", username)
+		w.WriteHeader(gemini.StatusNotFound, "rss feed not found")
+		return
+	}
+	posts, err := dbpool.FindUserPostsByTag(tag, user.ID, cfg.Space)
 	if err != nil {
 		logger.Error(err)
 		w.WriteHeader(gemini.StatusTemporaryFailure, err.Error())
@@ -456,59 +796,108 @@ func rssHandler(ctx context.Context, w gemini.ResponseWriter, r *gemini.Request)
 	}
 
 	feed := &feeds.Feed{
-		Title:       fmt.Sprintf("%s discovery feed", cfg.Domain),
-		Link:        &feeds.Link{Href: cfg.ReadURL()},
-		Description: fmt.Sprintf("%s latest posts", cfg.Domain),
-		Author:      &feeds.Author{Name: cfg.Domain},
+		Title:       fmt.Sprintf("#%s on %s", tag, internal.GetBlogName(username)),
+		Link:        &feeds.Link{Href: cfg.BlogTagURL(username, tag)},
+		Description: fmt.Sprintf("latest posts tagged #%s", tag),
+		Author:      &feeds.Author{Name: username},
 		Created:     time.Now(),
+		Items:       createFeedItems(cfg, posts),
 	}
 
-	var feedItems []*feeds.Item
-	for _, post := range pager.Data {
-		parsed := pkg.ParseText(post.Text)
-		var tpl bytes.Buffer
-		data := &internal.PostPageData{
-			ListType: parsed.MetaData.ListType,
-			Items:    parsed.Items,
-		}
-		if err := ts.Execute(&tpl, data); err != nil {
-			continue
-		}
+	writeFeed(ctx, w, feed)
+}
 
-		item := &feeds.Item{
-			Id:      cfg.PostURL(post.Username, post.Filename),
-			Title:   post.Title,
-			Link:    &feeds.Link{Href: cfg.PostURL(post.Username, post.Filename)},
-			Content: tpl.String(),
-			Created: *post.PublishAt,
+func rssBlogHandler(ctx context.Context, w gemini.ResponseWriter, r *gemini.Request) {
+	username := GetField(ctx, 0)
+	dbpool := GetDB(ctx)
+	logger := GetLogger(ctx)
+	cfg := GetCfg(ctx)
+
+	user, err := dbpool.FindUserForName(username)
+	if err != nil {
+		logger.Infof("rss feed not found: %s", username)
+		w.WriteHeader(gemini.StatusNotFound, "rss feed not found")
+		return
+	}
+	posts, err := dbpool.FindUpdatedPostsForUser(user.ID, cfg.Space)
+	if err != nil {
+		logger.Error(err)
+		w.WriteHeader(gemini.StatusTemporaryFailure, err.Error())
+		return
+	}
+
+	headerTxt := &internal.HeaderTxt{
+		Title: internal.GetBlogName(username),
+	}
+
+	for _, post := range posts {
+		if post.Filename == "_header" {
+			parsedText := cfg.ParseText(post.Text)
+			if parsedText.MetaData.Title != "" {
+				headerTxt.Title = parsedText.MetaData.Title
+			}
+
+			if parsedText.MetaData.Description != "" {
+				headerTxt.Bio = parsedText.MetaData.Description
+			}
+
+			break
 		}
+	}
 
-		if post.Description != "" {
-			item.Description = post.Description
+	var feedPosts []*db.Post
+	for _, post := range posts {
+		if slices.Contains(internal.HiddenPosts, post.Filename) {
+			continue
 		}
+		feedPosts = append(feedPosts, post)
+	}
 
-		feedItems = append(feedItems, item)
+	feed := &feeds.Feed{
+		Title:       headerTxt.Title,
+		Link:        &feeds.Link{Href: cfg.BlogURL(username)},
+		Description: headerTxt.Bio,
+		Author:      &feeds.Author{Name: username},
+		Created:     time.Now(),
+		Items:       createFeedItems(cfg, feedPosts),
 	}
-	feed.Items = feedItems
 
-	rss, err := feed.ToAtom()
+	writeFeed(ctx, w, feed)
+}
+
+func rssHandler(ctx context.Context, w gemini.ResponseWriter, r *gemini.Request) {
+	dbpool := GetDB(ctx)
+	logger := GetLogger(ctx)
+	cfg := GetCfg(ctx)
+
+	pager, err := dbpool.FindAllPosts(&db.Pager{Num: 25, Page: 0}, cfg.Space)
 	if err != nil {
 		logger.Error(err)
-		w.WriteHeader(gemini.StatusTemporaryFailure, "Could not generate atom rss feed")
+		w.WriteHeader(gemini.StatusTemporaryFailure, err.Error())
+		return
 	}
 
-	// w.Header().Add("Content-Type", "application/atom+xml")
-	_, err = w.Write([]byte(rss))
-	if err != nil {
-		logger.Error(err)
+	feed := &feeds.Feed{
+		Title:       fmt.Sprintf("%s discovery feed", cfg.Domain),
+		Link:        &feeds.Link{Href: cfg.ReadURL()},
+		Description: fmt.Sprintf("%s latest posts", cfg.Domain),
+		Author:      &feeds.Author{Name: cfg.Domain},
+		Created:     time.Now(),
+		Items:       createFeedItems(cfg, pager.Data),
 	}
+
+	writeFeed(ctx, w, feed)
 }
 
 func StartServer() {
 	cfg := internal.NewConfigSite()
-	db := postgres.NewDB(&cfg.ConfigCms)
+	db := storage.NewDB(&cfg.ConfigCms)
 	logger := cfg.Logger
 
+	if err := parsePageTemplates(cfg); err != nil {
+		logger.Fatal(err)
+	}
+
 	certificates := &certificate.Store{}
 	certificates.Register("localhost")
 	certificates.Register(cfg.Domain)
@@ -518,18 +907,35 @@ func StartServer() {
 	}
 
 	routes := []Route{
-		NewRoute("/", createPageHandler("./gmi/marketing.page.tmpl")),
-		NewRoute("/spec", createPageHandler("./gmi/spec.page.tmpl")),
-		NewRoute("/help", createPageHandler("./gmi/help.page.tmpl")),
-		NewRoute("/ops", createPageHandler("./gmi/ops.page.tmpl")),
-		NewRoute("/privacy", createPageHandler("./gmi/privacy.page.tmpl")),
+		NewRoute("/", createPageHandler("gmi/marketing.page.tmpl")),
+		NewRoute("/spec", createPageHandler("gmi/spec.page.tmpl")),
+		NewRoute("/help", createPageHandler("gmi/help.page.tmpl")),
+		NewRoute("/ops", createPageHandler("gmi/ops.page.tmpl")),
+		NewRoute("/privacy", createPageHandler("gmi/privacy.page.tmpl")),
 		NewRoute("/transparency", transparencyHandler),
 		NewRoute("/read", readHandler),
+		NewRoute("/search(?:/([0-9]+))?", searchHandler),
 		NewRoute("/rss", rssHandler),
+		NewRoute("/tags/([^/]+)", tagHandler),
+		NewRoute("/tags/([^/]+)/rss", rssTagHandler),
 		NewRoute("/([^/]+)", blogHandler),
 		NewRoute("/([^/]+)/rss", rssBlogHandler),
+		NewRoute("/([^/]+)/search(?:/([0-9]+))?", blogSearchHandler),
+		NewRoute("/([^/]+)/tags/([^/]+)", blogTagHandler),
+		NewRoute("/([^/]+)/tags/([^/]+)/rss", rssBlogTagHandler),
+		NewRoute("/([^/]+)/([^/]+)/items/([^/]+)", postItemHandler),
 		NewRoute("/([^/]+)/([^/]+)", postHandler),
 	}
+
+	for _, route := range routes {
+		if err := internal.CheckReservedRoute(route.regex.String()); err != nil {
+			logger.Fatal(err)
+		}
+		if err := internal.CheckReservedFilenameRoute(route.regex.String(), false); err != nil {
+			logger.Fatal(err)
+		}
+	}
+
 	handler := CreateServe(routes, cfg, db, logger)
 	router := gemini.HandlerFunc(handler)
 
@@ -0,0 +1,121 @@
+package gemini
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"git.sr.ht/~erock/lists.sh/internal"
+	"git.sr.ht/~erock/lists.sh/pkg"
+	"git.sr.ht/~erock/wish/cms/config"
+)
+
+func TestPostTocLinksToItems(t *testing.T) {
+	cfg := &internal.ConfigSite{
+		ConfigCms: config.ConfigCms{Domain: "lists.sh", Protocol: "https", Space: "lists"},
+	}
+	parsed := pkg.ParseText(`# Fruit
+apples
+## Red
+cherries
+# Vegetables {#veg}
+carrots
+# Drinks
+`)
+
+	data := internal.PostPageData{
+		Site:  *cfg.GetSiteData(),
+		URL:   "/erock/groceries",
+		Title: "groceries",
+		Toc:   internal.CreateTocData(cfg, "erock", "groceries", parsed.Toc()),
+	}
+
+	ts, err := renderTemplate(cfg, []string{"gmi/post.page.tmpl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	err = ts.Execute(&buf, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := strings.Join([]string{
+		"## Contents",
+		"=> /erock/groceries/items/fruit Fruit",
+		"=> /erock/groceries/items/red - Red",
+		"=> /erock/groceries/items/veg Vegetables",
+		"=> /erock/groceries/items/drinks Drinks",
+	}, "\n")
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("table of contents is missing from:\n%s", buf.String())
+	}
+}
+
+func TestParsePageTemplates(t *testing.T) {
+	cfg := &internal.ConfigSite{
+		ConfigCms: config.ConfigCms{Domain: "lists.sh", Protocol: "https", Space: "lists"},
+	}
+	err := parsePageTemplates(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSearchRoutesReadPage(t *testing.T) {
+	tests := []struct {
+		route  Route
+		path   string
+		fields []string
+	}{
+		{NewRoute("/search(?:/([0-9]+))?", searchHandler), "/search", []string{""}},
+		{NewRoute("/search(?:/([0-9]+))?", searchHandler), "/search/2", []string{"2"}},
+		{NewRoute("/([^/]+)/search(?:/([0-9]+))?", blogSearchHandler), "/erock/search", []string{"erock", ""}},
+		{NewRoute("/([^/]+)/search(?:/([0-9]+))?", blogSearchHandler), "/erock/search/3", []string{"erock", "3"}},
+	}
+
+	for _, tt := range tests {
+		matches := tt.route.regex.FindStringSubmatch(tt.path)
+		if matches == nil {
+			t.Errorf("%s did not match", tt.path)
+			continue
+		}
+		if strings.Join(matches[1:], ",") != strings.Join(tt.fields, ",") {
+			t.Errorf("%s: got fields %q, want %q", tt.path, matches[1:], tt.fields)
+		}
+	}
+}
+
+func TestSearchPageLinks(t *testing.T) {
+	cfg := &internal.ConfigSite{
+		ConfigCms: config.ConfigCms{Domain: "lists.sh", Protocol: "https", Space: "lists"},
+	}
+	data := internal.ReadPageData{
+		Site:      *cfg.GetSiteData(),
+		Query:     "milk eggs",
+		SearchURL: "/erock/search",
+		NextPage:  searchPageURL("/erock/search", "milk eggs", 2),
+		PrevPage:  searchPageURL("/erock/search", "milk eggs", 0),
+	}
+
+	ts, err := renderTemplate(cfg, []string{"gmi/search.page.tmpl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	err = ts.Execute(&buf, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"=> /erock/search/2?milk+eggs next",
+		"=> /erock/search/0?milk+eggs prev",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("%q is missing from:\n%s", want, buf.String())
+		}
+	}
+}
@@ -6,7 +6,7 @@ import (
 
 	"git.sr.ht/~adnano/go-gemini"
 	"git.sr.ht/~erock/lists.sh/internal"
-	"git.sr.ht/~erock/wish/cms/db"
+	"git.sr.ht/~erock/lists.sh/internal/storage"
 	"go.uber.org/zap"
 )
 
@@ -23,8 +23,8 @@ func GetCfg(ctx context.Context) *internal.ConfigSite {
 	return ctx.Value(ctxCfgKey{}).(*internal.ConfigSite)
 }
 
-func GetDB(ctx context.Context) db.DB {
-	return ctx.Value(ctxDBKey{}).(db.DB)
+func GetDB(ctx context.Context) storage.DB {
+	return ctx.Value(ctxDBKey{}).(storage.DB)
 }
 
 func GetField(ctx context.Context, index int) string {
@@ -46,7 +46,7 @@ func NewRoute(pattern string, handler gemini.HandlerFunc) Route {
 
 type ServeFn func(context.Context, gemini.ResponseWriter, *gemini.Request)
 
-func CreateServe(routes []Route, cfg *internal.ConfigSite, dbpool db.DB, logger *zap.SugaredLogger) ServeFn {
+func CreateServe(routes []Route, cfg *internal.ConfigSite, dbpool storage.DB, logger *zap.SugaredLogger) ServeFn {
 	return func(ctx context.Context, w gemini.ResponseWriter, r *gemini.Request) {
 		curRoutes := routes
 
@@ -0,0 +1,351 @@
+package internal
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"git.sr.ht/~erock/lists.sh/internal/storage"
+	"git.sr.ht/~erock/lists.sh/pkg"
+	"git.sr.ht/~erock/wish/cms/db"
+	sendutils "git.sr.ht/~erock/wish/send/utils"
+)
+
+// HistoryLimit is how many revisions the history of a post diffs, older
+// ones can still be restored by their id.
+var HistoryLimit = 50
+
+type DiffLineData struct {
+	Op   string
+	Line string
+}
+
+type RevisionData struct {
+	URL          template.URL
+	CreatedAt    string
+	CreatedAtISO string
+	IsFirst      bool
+	IsCurrent    bool
+	Added        int
+	Removed      int
+	Diff         []DiffLineData
+}
+
+type HistoryPageData struct {
+	Site      SitePageData
+	PageTitle string
+	URL       template.URL
+	BlogURL   template.URL
+	Title     string
+	Username  string
+	BlogName  string
+	Revisions []RevisionData
+	Older     int
+}
+
+func GetRevisionFromRequest(r *http.Request) string {
+	subdomain := GetSubdomain(r)
+
+	var revisionID string
+	if subdomain == "" {
+		revisionID, _ = url.PathUnescape(GetField(r, 2))
+	} else {
+		revisionID, _ = url.PathUnescape(GetField(r, 1))
+	}
+
+	return revisionID
+}
+
+// FindBlogName prefers the title from the readme, then the header, just
+// like the post page.
+func FindBlogName(dbpool storage.DB, cfg *ConfigSite, user *db.User) string {
+	blogName := GetBlogName(user.Name)
+	for _, filename := range []string{"_header", "_readme"} {
+		post, err := dbpool.FindPostWithFilename(filename, user.ID, cfg.Space)
+		if err != nil {
+			continue
+		}
+		parsed := cfg.ParseText(post.Text)
+		if parsed.MetaData.Title != "" {
+			blogName = parsed.MetaData.Title
+		}
+	}
+	return blogName
+}
+
+// findHistory loads the revisions createRevisionData needs, the latest
+// HistoryLimit plus the one before them, and how many revisions there are.
+func findHistory(dbpool storage.DB, post *db.Post) ([]*storage.Revision, int, error) {
+	revs, err := dbpool.FindRevisionsForPost(post.ID, HistoryLimit+1)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := dbpool.CountRevisionsForPost(post.ID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return revs, total, nil
+}
+
+// createRevisionData diffs the latest revisions, up to HistoryLimit,
+// against the one before them.  Revisions are expected newest first and
+// total is how many the post has.
+func createRevisionData(cfg *ConfigSite, post *db.Post, revs []*storage.Revision, total int) []RevisionData {
+	data := []RevisionData{}
+	if len(revs) == 0 {
+		return data
+	}
+
+	// every revision is parsed once, it is the newer side of its own diff
+	// and the older side of the next one
+	after := cfg.ParseText(revs[0].Text).Items
+	for i, rev := range revs {
+		if i >= HistoryLimit {
+			break
+		}
+		var before []*pkg.ListItem
+		if i+1 < len(revs) {
+			before = cfg.ParseText(revs[i+1].Text).Items
+		}
+		diff := pkg.DiffItems(before, after)
+		after = before
+		added, removed := pkg.DiffStats(diff)
+
+		lines := []DiffLineData{}
+		for _, item := range diff {
+			if item.Op == pkg.DiffSame {
+				continue
+			}
+			lines = append(lines, DiffLineData{Op: item.Op, Line: item.Line})
+		}
+
+		data = append(data, RevisionData{
+			URL:          template.URL(cfg.PostRevisionURL(post.Username, post.Filename, rev.ID)),
+			CreatedAt:    rev.CreatedAt.Format("02 Jan, 2006 15:04"),
+			CreatedAtISO: rev.CreatedAt.Format(time.RFC3339),
+			IsFirst:      i+1 == total,
+			IsCurrent:    i == 0,
+			Added:        added,
+			Removed:      removed,
+			Diff:         lines,
+		})
+	}
+	return data
+}
+
+func postHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	username := GetUsernameFromRequest(r)
+	filename := GetFilenameFromRequest(r)
+	dbpool := GetDB(r)
+	logger := GetLogger(r)
+	cfg := GetCfg(r)
+
+	user, err := dbpool.FindUserForName(username)
+	if err != nil {
+		logger.Infof("blog not found: %s", username)
+		http.Error(w, "blog not found", http.StatusNotFound)
+		return
+	}
+
+	post, err := dbpool.FindPostWithFilename(filename, user.ID, cfg.Space)
+	if err != nil {
+		logger.Infof("post not found %s/%s", username, filename)
+		http.Error(w, "post not found", http.StatusNotFound)
+		return
+	}
+
+	if CheckNotModified(w, r, NewestUpdatedAt(post), "history") {
+		return
+	}
+
+	revs, total, err := findHistory(dbpool, post)
+	if err != nil {
+		logger.Error(err)
+		http.Error(w, "could not fetch history", http.StatusInternalServerError)
+		return
+	}
+
+	title := FilenameToTitle(post.Filename, post.Title)
+	revisions := createRevisionData(cfg, post, revs, total)
+	data := HistoryPageData{
+		Site:      *cfg.GetSiteData(),
+		PageTitle: fmt.Sprintf("history of %s", title),
+		URL:       template.URL(cfg.PostURL(post.Username, post.Filename)),
+		BlogURL:   template.URL(cfg.BlogURL(username)),
+		Title:     title,
+		Username:  username,
+		BlogName:  FindBlogName(dbpool, cfg, user),
+		Revisions: revisions,
+		Older:     total - len(revisions),
+	}
+
+	ts, err := renderTemplate(cfg, []string{
+		"html/history.page.tmpl",
+	})
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	err = ts.Execute(w, data)
+	if err != nil {
+		logger.Error(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// postRevisionHandler shows a post the way it looked at a revision.
+func postRevisionHandler(w http.ResponseWriter, r *http.Request) {
+	username := GetUsernameFromRequest(r)
+	filename := GetFilenameFromRequest(r)
+	revisionID := GetRevisionFromRequest(r)
+	dbpool := GetDB(r)
+	logger := GetLogger(r)
+	cfg := GetCfg(r)
+
+	setVaryHeader(w)
+	format := NegotiateFormat(r)
+
+	user, err := dbpool.FindUserForName(username)
+	if err != nil {
+		logger.Infof("blog not found: %s", username)
+		writeFormatError(w, r, format, http.StatusNotFound, "blog not found")
+		return
+	}
+
+	post, err := dbpool.FindPostWithFilename(filename, user.ID, cfg.Space)
+	if err != nil {
+		logger.Infof("post not found %s/%s", username, filename)
+		writeFormatError(w, r, format, http.StatusNotFound, "post not found")
+		return
+	}
+
+	rev, err := dbpool.FindRevision(post.ID, revisionID)
+	if err != nil {
+		logger.Infof("revision not found %s/%s/%s", username, filename, revisionID)
+		writeFormatError(w, r, format, http.StatusNotFound, "revision not found")
+		return
+	}
+
+	// revisions never change once they are written
+	if CheckNotModified(w, r, *rev.CreatedAt, format) {
+		return
+	}
+
+	parsedText := cfg.ParseText(rev.Text)
+	todosDone, todosTotal := parsedText.TodoProgress()
+	title := rev.Title
+	if parsedText.MetaData.Title != "" {
+		title = parsedText.MetaData.Title
+	}
+
+	data := PostPageData{
+		Site:         *cfg.GetSiteData(),
+		PageTitle:    fmt.Sprintf("%s (revision)", FilenameToTitle(post.Filename, title)),
+		URL:          template.URL(cfg.PostRevisionURL(post.Username, post.Filename, rev.ID)),
+		HistoryURL:   template.URL(cfg.PostHistoryURL(post.Username, post.Filename)),
+		CurrentURL:   template.URL(cfg.PostURL(post.Username, post.Filename)),
+		BlogURL:      template.URL(cfg.BlogURL(username)),
+		RevisionID:   rev.ID,
+		RevisionAt:   rev.CreatedAt.Format("02 Jan, 2006 15:04"),
+		Description:  parsedText.MetaData.Description,
+		ListType:     parsedText.MetaData.ListType,
+		Title:        FilenameToTitle(post.Filename, title),
+		PublishAt:    post.PublishAt.Format("02 Jan, 2006"),
+		PublishAtISO: post.PublishAt.Format(time.RFC3339),
+		Username:     username,
+		BlogName:     FindBlogName(dbpool, cfg, user),
+		Items:        parsedText.Items,
+		Toc:          CreateTocData(cfg, post.Username, post.Filename, parsedText.Toc()),
+		TodosDone:    todosDone,
+		TodosTotal:   todosTotal,
+		Tags:         CreateTagData(cfg, username, parsedText.MetaData.Tags),
+		Extra:        parsedText.MetaData.Extra,
+	}
+
+	writePostPage(w, r, format, &data)
+}
+
+var revisionPrefixRe = regexp.MustCompile(`^[0-9a-f-]+$`)
+
+// findRevision matches a revision by its id or a unique prefix of it so
+// users only have to type what `restore <post>` printed.
+func (h *DbHandler) findRevision(post *db.Post, prefix string) (*storage.Revision, error) {
+	prefix = strings.ToLower(prefix)
+	if !revisionPrefixRe.MatchString(prefix) {
+		return nil, fmt.Errorf("revision %s not found", prefix)
+	}
+
+	// two matches are enough to know the prefix is ambiguous
+	revs, err := h.DBPool.FindRevisionsWithPrefix(post.ID, prefix, 2)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(revs) == 0 {
+		return nil, fmt.Errorf("revision %s not found", prefix)
+	}
+	if len(revs) > 1 {
+		return nil, fmt.Errorf("revision %s is ambiguous", prefix)
+	}
+	return revs[0], nil
+}
+
+func (h *DbHandler) revisionList(post *db.Post) (string, error) {
+	revs, total, err := findHistory(h.DBPool, post)
+	if err != nil {
+		return "", err
+	}
+
+	msg := fmt.Sprintf("revisions of %s, newest first:\n\n", post.Filename)
+	revisions := createRevisionData(h.Cfg, post, revs, total)
+	for i, data := range revisions {
+		rev := revs[i]
+		msg += fmt.Sprintf("  %s  %s  +%d -%d\n", rev.ID[:8], rev.CreatedAt.Format("2006-01-02 15:04"), data.Added, data.Removed)
+	}
+	if total > len(revisions) {
+		msg += fmt.Sprintf("  ... %d older revisions\n", total-len(revisions))
+	}
+	msg += fmt.Sprintf("\nrestore one with: ssh %s restore %s <revision>", h.Cfg.Domain, post.Filename)
+	return msg, nil
+}
+
+// Restore lists the revisions of a post or uploads an old revision again:
+//
+//	restore <post>              list the revisions
+//	restore <post> <revision>   restore the revision
+func (h *DbHandler) Restore(user *db.User, args []string) (string, error) {
+	if len(args) == 0 {
+		return "", fmt.Errorf("usage: ssh %s restore <post> [revision]", h.Cfg.Domain)
+	}
+
+	filename := SanitizeFileExt(args[0])
+	post, err := h.DBPool.FindPostWithFilename(filename, user.ID, h.Cfg.Space)
+	if err != nil {
+		return "", fmt.Errorf("post %s not found", filename)
+	}
+
+	if len(args) == 1 {
+		return h.revisionList(post)
+	}
+
+	rev, err := h.findRevision(post, args[1])
+	if err != nil {
+		return "", err
+	}
+
+	// restoring goes through the upload so it becomes the newest revision
+	name := fmt.Sprintf("%s.txt", post.Filename)
+	return h.WritePost(user, &sendutils.FileEntry{
+		Name:     name,
+		Filepath: name,
+		Size:     int64(len(rev.Text)),
+		Reader:   strings.NewReader(rev.Text),
+	}, &WriteOptions{})
+}
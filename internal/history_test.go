@@ -0,0 +1,59 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"git.sr.ht/~erock/lists.sh/internal/storage"
+	"git.sr.ht/~erock/wish/cms/config"
+	"git.sr.ht/~erock/wish/cms/db"
+)
+
+func TestCreateRevisionData(t *testing.T) {
+	cfg := &ConfigSite{ConfigCms: config.ConfigCms{Domain: "lists.sh"}}
+	post := &db.Post{Username: "erock", Filename: "groceries"}
+	now := time.Now()
+	revs := []*storage.Revision{
+		{ID: "c", Text: "milk\neggs\nham\n", CreatedAt: &now},
+		{ID: "b", Text: "milk\neggs\n", CreatedAt: &now},
+		{ID: "a", Text: "milk\n", CreatedAt: &now},
+	}
+
+	data := createRevisionData(cfg, post, revs, len(revs))
+	if len(data) != 3 {
+		t.Fatalf("got %d revisions, want 3", len(data))
+	}
+	for i, want := range []struct{ added, removed int }{{1, 0}, {1, 0}, {1, 0}} {
+		if data[i].Added != want.added || data[i].Removed != want.removed {
+			t.Errorf("revision %d: got +%d -%d, want +%d -%d", i, data[i].Added, data[i].Removed, want.added, want.removed)
+		}
+	}
+	if !data[0].IsCurrent || !data[2].IsFirst || data[1].IsFirst {
+		t.Errorf("current and first are wrong: %+v", data)
+	}
+}
+
+func TestCreateRevisionDataStopsAtHistoryLimit(t *testing.T) {
+	limit := HistoryLimit
+	HistoryLimit = 1
+	defer func() { HistoryLimit = limit }()
+
+	cfg := &ConfigSite{ConfigCms: config.ConfigCms{Domain: "lists.sh"}}
+	post := &db.Post{Username: "erock", Filename: "groceries"}
+	now := time.Now()
+	revs := []*storage.Revision{
+		{ID: "c", Text: "milk\nham\n", CreatedAt: &now},
+		{ID: "b", Text: "milk\neggs\n", CreatedAt: &now},
+	}
+
+	data := createRevisionData(cfg, post, revs, 5)
+	if len(data) != 1 {
+		t.Fatalf("got %d revisions, want 1", len(data))
+	}
+	if data[0].Added != 1 || data[0].Removed != 1 {
+		t.Errorf("got +%d -%d, want +1 -1", data[0].Added, data[0].Removed)
+	}
+	if data[0].IsFirst {
+		t.Error("the newest of five revisions is not the first upload")
+	}
+}
@@ -0,0 +1,109 @@
+package internal
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"git.sr.ht/~erock/lists.sh/internal/storage"
+)
+
+var HostApp = "app"
+var HostSubdomain = "subdomain"
+var HostCustom = "custom"
+var HostRedirect = "redirect"
+
+// Host is the result of matching a request's Host header against the
+// domains we serve.
+type Host struct {
+	Kind string
+	// Name is the hostname without the port.
+	Name string
+	// Subdomain is the username for HostSubdomain.
+	Subdomain string
+	// Redirect is the host to send the client to for HostRedirect.
+	Redirect string
+}
+
+var hostnameRe = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?)(\.[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?)*$`)
+
+func splitHostPort(host string) (string, string) {
+	name, port, err := net.SplitHostPort(host)
+	if err != nil {
+		return host, ""
+	}
+	return name, port
+}
+
+func joinHostPort(name, port string) string {
+	if port == "" {
+		return name
+	}
+	return net.JoinHostPort(name, port)
+}
+
+// Domains returns the primary domain followed by the aliases, without
+// ports.
+func (c *ConfigSite) Domains() []string {
+	primary, _ := splitHostPort(strings.ToLower(c.Domain))
+	domains := []string{primary}
+	for _, alias := range c.DomainAliases {
+		name, _ := splitHostPort(strings.ToLower(alias))
+		domains = append(domains, name)
+	}
+	return domains
+}
+
+// MatchHost figures out how to serve a Host header.  Only exact matches of
+// the primary or alias domains, or a single label below them, are served by
+// us.  `www.` is redirected to the same host without it.  Hostnames without
+// a dot (e.g. `localhost` or the name of the container) are served the app
+// so internal requests keep working.  Anything else is treated as a custom
+// domain, and invalid hosts are redirected to the primary domain.
+func (c *ConfigSite) MatchHost(rawHost string) *Host {
+	name, port := splitHostPort(strings.ToLower(rawHost))
+	name = strings.TrimSuffix(name, ".")
+
+	if net.ParseIP(name) != nil {
+		return &Host{Kind: HostApp, Name: name}
+	}
+
+	primary := &Host{Kind: HostRedirect, Name: name, Redirect: c.Domain}
+	if name == "" || len(name) > 253 || !hostnameRe.MatchString(name) {
+		return primary
+	}
+
+	if !strings.Contains(name, ".") {
+		return &Host{Kind: HostApp, Name: name}
+	}
+
+	for _, domain := range c.Domains() {
+		if name == domain {
+			return &Host{Kind: HostApp, Name: name}
+		}
+
+		suffix := fmt.Sprintf(".%s", domain)
+		if !strings.HasSuffix(name, suffix) {
+			continue
+		}
+
+		label := strings.TrimSuffix(name, suffix)
+		if label == "www" {
+			return &Host{Kind: HostRedirect, Name: name, Redirect: joinHostPort(domain, port)}
+		}
+
+		// `www.erock.lists.sh` is a typo away from `erock.lists.sh`
+		if strings.HasPrefix(label, "www.") {
+			return &Host{Kind: HostRedirect, Name: name, Redirect: joinHostPort(strings.TrimPrefix(name, "www."), port)}
+		}
+
+		if !c.IsSubdomains() || strings.Contains(label, ".") || storage.IsReservedName(label) {
+			return primary
+		}
+
+		return &Host{Kind: HostSubdomain, Name: name, Subdomain: label}
+	}
+
+	return &Host{Kind: HostCustom, Name: name}
+}
@@ -0,0 +1,60 @@
+package internal
+
+import (
+	"testing"
+
+	"git.sr.ht/~erock/wish/cms/config"
+)
+
+func TestMatchHost(t *testing.T) {
+	cfg := &ConfigSite{
+		ConfigCms:         config.ConfigCms{Domain: "lists.sh"},
+		SubdomainsEnabled: true,
+		DomainAliases:     []string{"lists.example:8443"},
+	}
+
+	cases := []struct {
+		host string
+		want Host
+	}{
+		{host: "lists.sh", want: Host{Kind: HostApp, Name: "lists.sh"}},
+		{host: "LISTS.SH.", want: Host{Kind: HostApp, Name: "lists.sh"}},
+		{host: "lists.sh:443", want: Host{Kind: HostApp, Name: "lists.sh"}},
+		{host: "www.lists.sh", want: Host{Kind: HostRedirect, Name: "www.lists.sh", Redirect: "lists.sh"}},
+		{host: "www.lists.sh:443", want: Host{Kind: HostRedirect, Name: "www.lists.sh", Redirect: "lists.sh:443"}},
+		{host: "foo.lists.sh", want: Host{Kind: HostSubdomain, Name: "foo.lists.sh", Subdomain: "foo"}},
+		{host: "foo.lists.sh:443", want: Host{Kind: HostSubdomain, Name: "foo.lists.sh", Subdomain: "foo"}},
+		{host: "www.foo.lists.sh", want: Host{Kind: HostRedirect, Name: "www.foo.lists.sh", Redirect: "foo.lists.sh"}},
+		{host: "a.b.lists.sh", want: Host{Kind: HostRedirect, Name: "a.b.lists.sh", Redirect: "lists.sh"}},
+		{host: "api.lists.sh", want: Host{Kind: HostRedirect, Name: "api.lists.sh", Redirect: "lists.sh"}},
+		{host: "admin.lists.sh", want: Host{Kind: HostRedirect, Name: "admin.lists.sh", Redirect: "lists.sh"}},
+		{host: "evillists.sh", want: Host{Kind: HostCustom, Name: "evillists.sh"}},
+		{host: "lists.example", want: Host{Kind: HostApp, Name: "lists.example"}},
+		{host: "foo.lists.example:8443", want: Host{Kind: HostSubdomain, Name: "foo.lists.example", Subdomain: "foo"}},
+		{host: "blog.example.com", want: Host{Kind: HostCustom, Name: "blog.example.com"}},
+		{host: "127.0.0.1:3000", want: Host{Kind: HostApp, Name: "127.0.0.1"}},
+		{host: "[::1]:3000", want: Host{Kind: HostApp, Name: "::1"}},
+		{host: "localhost:3000", want: Host{Kind: HostApp, Name: "localhost"}},
+		{host: "", want: Host{Kind: HostRedirect, Name: "", Redirect: "lists.sh"}},
+		{host: "bad_host.lists.sh", want: Host{Kind: HostRedirect, Name: "bad_host.lists.sh", Redirect: "lists.sh"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.host, func(t *testing.T) {
+			got := cfg.MatchHost(tc.host)
+			if *got != tc.want {
+				t.Errorf("got %+v, want %+v", *got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMatchHostWithoutSubdomains(t *testing.T) {
+	cfg := &ConfigSite{ConfigCms: config.ConfigCms{Domain: "lists.sh"}}
+
+	got := cfg.MatchHost("foo.lists.sh")
+	want := Host{Kind: HostRedirect, Name: "foo.lists.sh", Redirect: "lists.sh"}
+	if *got != want {
+		t.Errorf("got %+v, want %+v", *got, want)
+	}
+}
@@ -0,0 +1,171 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"time"
+
+	"git.sr.ht/~erock/wish/cms/db"
+	"golang.org/x/exp/slices"
+)
+
+type BlogJSONData struct {
+	Username    string         `json:"username"`
+	Title       string         `json:"title"`
+	Description string         `json:"description"`
+	URL         template.URL   `json:"url"`
+	RSSURL      template.URL   `json:"rss_url"`
+	Posts       []PostItemData `json:"posts"`
+}
+
+type ReadJSONData struct {
+	Page     int            `json:"page"`
+	Total    int            `json:"total"`
+	NextPage string         `json:"next_page,omitempty"`
+	PrevPage string         `json:"prev_page,omitempty"`
+	Posts    []PostItemData `json:"posts"`
+}
+
+type errorJSONData struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, data interface{}) {
+	logger := GetLogger(r)
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(data)
+	if err != nil {
+		logger.Error(err)
+	}
+}
+
+func writeJSONError(w http.ResponseWriter, r *http.Request, status int, msg string) {
+	writeJSON(w, r, status, &errorJSONData{Error: msg})
+}
+
+func createPostItemData(cfg *ConfigSite, post *db.Post, tags []string) PostItemData {
+	return PostItemData{
+		URL:            template.URL(cfg.PostURL(post.Username, post.Filename)),
+		BlogURL:        template.URL(cfg.BlogURL(post.Username)),
+		Username:       post.Username,
+		Title:          FilenameToTitle(post.Filename, post.Title),
+		Description:    post.Description,
+		PublishAt:      post.PublishAt.Format("02 Jan, 2006"),
+		PublishAtISO:   post.PublishAt.Format(time.RFC3339),
+		UpdatedTimeAgo: TimeAgo(post.UpdatedAt),
+		UpdatedAtISO:   post.UpdatedAt.Format(time.RFC3339),
+		Tags:           CreateTagData(cfg, post.Username, tags),
+	}
+}
+
+func apiBlogHandler(w http.ResponseWriter, r *http.Request) {
+	username := GetUsernameFromRequest(r)
+	dbpool := GetDB(r)
+	logger := GetLogger(r)
+	cfg := GetCfg(r)
+
+	user, err := dbpool.FindUserForName(username)
+	if err != nil {
+		logger.Infof("blog not found: %s", username)
+		writeJSONError(w, r, http.StatusNotFound, "blog not found")
+		return
+	}
+	posts, err := dbpool.FindUpdatedPostsForUser(user.ID, cfg.Space)
+	if err != nil {
+		logger.Error(err)
+		writeJSONError(w, r, http.StatusInternalServerError, "could not fetch posts for blog")
+		return
+	}
+
+	data := BlogJSONData{
+		Username: username,
+		Title:    GetBlogName(username),
+		URL:      template.URL(cfg.BlogURL(username)),
+		RSSURL:   template.URL(cfg.RssBlogURL(username)),
+		Posts:    make([]PostItemData, 0, len(posts)),
+	}
+
+	tags := FindPostTags(dbpool, logger, posts)
+	for _, post := range posts {
+		if post.Filename == "_header" {
+			parsedText := cfg.ParseText(post.Text)
+			if parsedText.MetaData.Title != "" {
+				data.Title = parsedText.MetaData.Title
+			}
+
+			if parsedText.MetaData.Description != "" {
+				data.Description = parsedText.MetaData.Description
+			}
+		} else if !slices.Contains(HiddenPosts, post.Filename) {
+			data.Posts = append(data.Posts, createPostItemData(cfg, post, tags[post.ID]))
+		}
+	}
+
+	writeJSON(w, r, http.StatusOK, data)
+}
+
+func apiPostHandler(w http.ResponseWriter, r *http.Request) {
+	username := GetUsernameFromRequest(r)
+	filename := GetFilenameFromRequest(r)
+	dbpool := GetDB(r)
+	logger := GetLogger(r)
+	cfg := GetCfg(r)
+
+	user, err := dbpool.FindUserForName(username)
+	if err != nil {
+		logger.Infof("blog not found: %s", username)
+		writeJSONError(w, r, http.StatusNotFound, "blog not found")
+		return
+	}
+
+	post, err := dbpool.FindPostWithFilename(filename, user.ID, cfg.Space)
+	if err != nil {
+		logger.Infof("post not found %s/%s", username, filename)
+		writeJSONError(w, r, http.StatusNotFound, "post not found")
+		return
+	}
+
+	parsedText := cfg.ParseText(post.Text)
+	data := createPostPageData(cfg, post, username, FindBlogName(dbpool, cfg, user), parsedText)
+
+	writeJSON(w, r, http.StatusOK, data)
+}
+
+func apiReadHandler(w http.ResponseWriter, r *http.Request) {
+	dbpool := GetDB(r)
+	logger := GetLogger(r)
+	cfg := GetCfg(r)
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	pager, err := dbpool.FindAllUpdatedPosts(&db.Pager{Num: 30, Page: page}, cfg.Space)
+	if err != nil {
+		logger.Error(err)
+		writeJSONError(w, r, http.StatusInternalServerError, "could not fetch posts")
+		return
+	}
+
+	data := ReadJSONData{
+		Page:  page,
+		Total: pager.Total,
+		Posts: make([]PostItemData, 0, len(pager.Data)),
+	}
+
+	if page < pager.Total-1 {
+		data.NextPage = fmt.Sprintf("/api/read?page=%d", page+1)
+	}
+	if page > 0 {
+		data.PrevPage = fmt.Sprintf("/api/read?page=%d", page-1)
+	}
+
+	tags := FindPostTags(dbpool, logger, pager.Data)
+	for _, post := range pager.Data {
+		data.Posts = append(data.Posts, createPostItemData(cfg, post, tags[post.ID]))
+	}
+
+	writeJSON(w, r, http.StatusOK, data)
+}
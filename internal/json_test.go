@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"git.sr.ht/~erock/wish/cms/config"
+	"git.sr.ht/~erock/wish/cms/db"
+	"go.uber.org/zap"
+)
+
+func TestApiPostMatchesNegotiatedJSON(t *testing.T) {
+	user := &db.User{ID: "user-1", Name: "erock"}
+	dbpool := newMemDB(user)
+	publishAt := time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC)
+	_, err := dbpool.InsertPost(user.ID, "groceries", "groceries", "=: tags food\nmilk\n", "", &publishAt, false, "lists")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := zap.NewNop().Sugar()
+	cfg := &ConfigSite{
+		ConfigCms: config.ConfigCms{Domain: "lists.sh", Protocol: "https", Space: "lists", Logger: logger},
+	}
+	routes := []Route{
+		NewRoute("GET", "/api/([^/]+)/([^/]+)", apiPostHandler),
+		NewRoute("GET", "/([^/]+)/([^/]+)", postHandler),
+	}
+	serve := CreateServe(routes, []Route{}, cfg, dbpool, logger)
+
+	fetch := func(path string) map[string]interface{} {
+		t.Helper()
+		req := httptest.NewRequest("GET", path, nil)
+		req.Host = "lists.sh"
+		req.Header.Set("Accept", "application/json")
+		rec := httptest.NewRecorder()
+		serve(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s returned %d: %s", path, rec.Code, rec.Body.String())
+		}
+
+		data := map[string]interface{}{}
+		err := json.Unmarshal(rec.Body.Bytes(), &data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	api := fetch("/api/erock/groceries")
+	negotiated := fetch("/erock/groceries")
+	if !reflect.DeepEqual(api, negotiated) {
+		t.Errorf("json differs:\n%v\n%v", api, negotiated)
+	}
+	if api["history_url"] != "/erock/groceries/history" {
+		t.Errorf("got history_url %v", api["history_url"])
+	}
+	if api["changes_url"] != "/erock/groceries/changes" {
+		t.Errorf("got changes_url %v", api["changes_url"])
+	}
+}
@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"fmt"
+	"time"
+
+	"git.sr.ht/~erock/lists.sh/internal/storage"
+	"git.sr.ht/~erock/wish/cms/db"
+)
+
+// memDB keeps the posts of a single user in memory.  Only the queries the
+// tests need are implemented, anything else panics.
+type memDB struct {
+	storage.DB
+	user  *db.User
+	posts map[string]*db.Post
+}
+
+func newMemDB(user *db.User) *memDB {
+	return &memDB{user: user, posts: map[string]*db.Post{}}
+}
+
+func (m *memDB) FindUserForKey(name string, key string) (*db.User, error) {
+	return m.user, nil
+}
+
+func (m *memDB) FindUserForName(name string) (*db.User, error) {
+	if name != m.user.Name {
+		return nil, fmt.Errorf("user %s not found", name)
+	}
+	return m.user, nil
+}
+
+func (m *memDB) AddViewCount(postID string) (int, error) {
+	return 1, nil
+}
+
+func (m *memDB) FindPostWithFilename(filename string, userID string, space string) (*db.Post, error) {
+	post, ok := m.posts[filename]
+	if !ok || post.UserID != userID {
+		return nil, fmt.Errorf("post %s not found", filename)
+	}
+	return post, nil
+}
+
+func (m *memDB) FindAllPostsForUser(userID string, space string) ([]*db.Post, error) {
+	posts := []*db.Post{}
+	for _, post := range m.posts {
+		if post.UserID == userID {
+			posts = append(posts, post)
+		}
+	}
+	return posts, nil
+}
+
+func (m *memDB) FindUpdatedPostsForUser(userID string, space string) ([]*db.Post, error) {
+	return m.FindAllPostsForUser(userID, space)
+}
+
+func (m *memDB) FindAllPosts(pager *db.Pager, space string) (*db.Paginate[*db.Post], error) {
+	posts := []*db.Post{}
+	for _, post := range m.posts {
+		if !post.Hidden {
+			posts = append(posts, post)
+		}
+	}
+	return &db.Paginate[*db.Post]{Data: posts, Total: 1}, nil
+}
+
+func (m *memDB) InsertPost(userID string, filename string, title string, text string, description string, publishAt *time.Time, hidden bool, space string) (*db.Post, error) {
+	now := time.Now()
+	post := &db.Post{
+		ID:          fmt.Sprintf("post-%d", len(m.posts)+1),
+		UserID:      userID,
+		Username:    m.user.Name,
+		Filename:    filename,
+		Title:       title,
+		Text:        text,
+		Description: description,
+		PublishAt:   publishAt,
+		UpdatedAt:   &now,
+		Hidden:      hidden,
+	}
+	m.posts[filename] = post
+	return post, nil
+}
+
+func (m *memDB) UpdatePost(postID string, title string, text string, description string, publishAt *time.Time) (*db.Post, error) {
+	for _, post := range m.posts {
+		if post.ID == postID {
+			post.Title = title
+			post.Text = text
+			post.Description = description
+			post.PublishAt = publishAt
+			return post, nil
+		}
+	}
+	return nil, fmt.Errorf("post %s not found", postID)
+}
+
+func (m *memDB) InsertRevision(postID string, title string, text string) (*storage.Revision, error) {
+	return &storage.Revision{PostID: postID, Title: title, Text: text}, nil
+}
+
+func (m *memDB) UpdatePostData(postID string, data *storage.PostData) error {
+	return nil
+}
+
+func (m *memDB) ReplaceTagsForPost(tags []string, postID string) error {
+	return nil
+}
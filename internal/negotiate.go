@@ -0,0 +1,192 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"git.sr.ht/~erock/lists.sh/pkg"
+	"git.sr.ht/~erock/lists.sh/pkg/render"
+)
+
+var FormatHTML = "html"
+var FormatText = "text"
+var FormatGemtext = "gemtext"
+var FormatMarkdown = "markdown"
+var FormatJSON = "json"
+
+var formatMediaTypes = map[string]string{
+	"text/html":             FormatHTML,
+	"application/xhtml+xml": FormatHTML,
+	"text/plain":            FormatText,
+	"text/gemini":           FormatGemtext,
+	"text/markdown":         FormatMarkdown,
+	"application/json":      FormatJSON,
+}
+
+var formatContentTypes = map[string]string{
+	FormatText:     "text/plain; charset=utf-8",
+	FormatGemtext:  "text/gemini; charset=utf-8",
+	FormatMarkdown: "text/markdown; charset=utf-8",
+}
+
+// terminalAgents are user agents of command line clients that get plain
+// text unless they explicitly ask for something else.
+var terminalAgents = []string{
+	"curl/",
+	"wget/",
+	"httpie/",
+	"xh/",
+	"fetch libfetch",
+	"lynx/",
+	"w3m/",
+}
+
+func isTerminalAgent(r *http.Request) bool {
+	agent := strings.ToLower(r.UserAgent())
+	for _, prefix := range terminalAgents {
+		if strings.HasPrefix(agent, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// NegotiateFormat picks the output format for a request from the highest
+// weighted media type in the Accept header that we know how to produce.
+// Wildcards fall back to html for browsers and plain text for terminals.
+func NegotiateFormat(r *http.Request) string {
+	fallback := FormatHTML
+	if isTerminalAgent(r) {
+		fallback = FormatText
+	}
+
+	format := ""
+	best := 0.0
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		weight := 1.0
+		if q, ok := params["q"]; ok {
+			weight, err = strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+		}
+
+		found, ok := formatMediaTypes[mediaType]
+		if !ok && (mediaType == "*/*" || mediaType == "text/*") {
+			found, ok = fallback, true
+		}
+
+		if ok && weight > best {
+			format = found
+			best = weight
+		}
+	}
+
+	if format == "" {
+		return fallback
+	}
+	return format
+}
+
+func setVaryHeader(w http.ResponseWriter) {
+	w.Header().Add("Vary", "Accept, User-Agent")
+}
+
+// writeFormatError responds with an error message in the negotiated format.
+func writeFormatError(w http.ResponseWriter, r *http.Request, format string, status int, msg string) {
+	if format == FormatJSON {
+		writeJSONError(w, r, status, msg)
+		return
+	}
+
+	http.Error(w, msg, status)
+}
+
+// writeDocument renders a title, a short description and the parsed list
+// in one of the text based formats.
+func writeDocument(w http.ResponseWriter, r *http.Request, format, title, description string, parsed *pkg.ParsedText) {
+	logger := GetLogger(r)
+
+	var renderer render.Renderer
+	var heading []string
+	switch format {
+	case FormatGemtext:
+		renderer = &render.GemtextRenderer{}
+		heading = []string{fmt.Sprintf("# %s", title)}
+	case FormatMarkdown:
+		renderer = &render.MarkdownRenderer{}
+		heading = []string{fmt.Sprintf("# %s", title), ""}
+	default:
+		renderer = &render.TextRenderer{}
+		heading = []string{title, strings.Repeat("=", len([]rune(title)))}
+	}
+
+	if description != "" {
+		heading = append(heading, description)
+	}
+
+	w.Header().Set("Content-Type", formatContentTypes[format])
+	_, err := io.WriteString(w, strings.Join(heading, "\n")+"\n\n")
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+
+	err = renderer.Render(w, parsed)
+	if err != nil {
+		logger.Error(err)
+	}
+}
+
+func writePostFormat(w http.ResponseWriter, r *http.Request, format string, data *PostPageData) {
+	if format == FormatJSON {
+		writeJSON(w, r, http.StatusOK, data)
+		return
+	}
+
+	description := fmt.Sprintf("%s on %s", data.PublishAt, data.BlogName)
+	if data.Description != "" {
+		description = fmt.Sprintf("%s\n%s", description, data.Description)
+	}
+
+	writeDocument(w, r, format, data.Title, description, render.Items(data.Items, data.ListType))
+}
+
+func writeBlogFormat(w http.ResponseWriter, r *http.Request, format string, data *BlogPageData) {
+	if format == FormatJSON {
+		writeJSON(w, r, http.StatusOK, &BlogJSONData{
+			Username:    data.Username,
+			Title:       data.Header.Title,
+			Description: data.Header.Bio,
+			URL:         data.URL,
+			RSSURL:      data.RSSURL,
+			Posts:       data.Posts,
+		})
+		return
+	}
+
+	items := []*pkg.ListItem{}
+	if data.Query == "" && data.Readme.HasItems {
+		items = append(items, data.Readme.Items...)
+		items = append(items, &pkg.ListItem{Value: "posts", IsHeaderOne: true})
+	}
+
+	for _, post := range data.Posts {
+		items = append(items, &pkg.ListItem{
+			Value: fmt.Sprintf("%s (%s)", post.Title, post.PublishAt),
+			URL:   post.URL,
+			IsURL: true,
+		})
+	}
+
+	writeDocument(w, r, format, data.Header.Title, data.Header.Bio, render.Items(items, data.Readme.ListType))
+}
@@ -0,0 +1,127 @@
+package internal
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+
+	"git.sr.ht/~erock/lists.sh/internal/storage"
+	"git.sr.ht/~erock/wish/cms/db"
+	"github.com/gliderlabs/ssh"
+)
+
+// PostFile is a post as the <filename>.txt file it was uploaded as.
+type PostFile struct {
+	Post *db.Post
+}
+
+func (f *PostFile) Name() string      { return fmt.Sprintf("%s.txt", f.Post.Filename) }
+func (f *PostFile) Size() int64       { return int64(len(f.Post.Text)) }
+func (f *PostFile) Mode() fs.FileMode { return 0644 }
+func (f *PostFile) IsDir() bool       { return false }
+func (f *PostFile) Sys() interface{}  { return nil }
+
+func (f *PostFile) ModTime() time.Time {
+	if f.Post.UpdatedAt != nil {
+		return *f.Post.UpdatedAt
+	}
+	return *f.Post.PublishAt
+}
+
+// rootDir is the only directory, every post lives in it.
+type rootDir struct{}
+
+func (rootDir) Name() string       { return "/" }
+func (rootDir) Size() int64        { return 0 }
+func (rootDir) Mode() fs.FileMode  { return fs.ModeDir | 0755 }
+func (rootDir) ModTime() time.Time { return time.Time{} }
+func (rootDir) IsDir() bool        { return true }
+func (rootDir) Sys() interface{}   { return nil }
+
+// Opener serves the posts of one user as files so scp and sftp can download
+// them.  It holds on to the user of the session since the handler is shared.
+type Opener struct {
+	User   *db.User
+	DBPool storage.DB
+	Cfg    *ConfigSite
+}
+
+func (h *DbHandler) NewOpener(s ssh.Session) (*Opener, error) {
+	user, err := h.FindSessionUser(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Opener{
+		User:   user,
+		DBPool: h.DBPool,
+		Cfg:    h.Cfg,
+	}, nil
+}
+
+// cleanFilepath makes every path the client sends absolute, `~` is the root
+// directory just like "/".
+func cleanFilepath(name string) string {
+	return path.Clean("/" + strings.TrimPrefix(name, "~"))
+}
+
+func IsRootDir(name string) bool {
+	return cleanFilepath(name) == "/"
+}
+
+// ReadDir lists every post, including hidden and scheduled ones.
+func (o *Opener) ReadDir() ([]*PostFile, error) {
+	posts, err := o.DBPool.FindAllPostsForUser(o.User.ID, o.Cfg.Space)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []*PostFile{}
+	for _, post := range posts {
+		files = append(files, &PostFile{Post: post})
+	}
+	return files, nil
+}
+
+// Glob lists the posts whose file name matches a pattern, e.g. `*.txt`.
+func (o *Opener) Glob(pattern string) ([]*PostFile, error) {
+	pattern = cleanFilepath(pattern)
+	if path.Dir(pattern) != "/" {
+		return []*PostFile{}, nil
+	}
+
+	files, err := o.ReadDir()
+	if err != nil {
+		return nil, err
+	}
+
+	matches := []*PostFile{}
+	for _, file := range files {
+		ok, err := path.Match(path.Base(pattern), file.Name())
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			matches = append(matches, file)
+		}
+	}
+	return matches, nil
+}
+
+// Open finds the post for a file name, fs.ErrNotExist is returned for
+// anything that is not a .txt file in the root directory.
+func (o *Opener) Open(name string) (*PostFile, error) {
+	name = cleanFilepath(name)
+	if path.Dir(name) != "/" || path.Ext(name) != ".txt" {
+		return nil, fs.ErrNotExist
+	}
+
+	post, err := o.DBPool.FindPostWithFilename(SanitizeFileExt(path.Base(name)), o.User.ID, o.Cfg.Space)
+	if err != nil {
+		return nil, fs.ErrNotExist
+	}
+
+	return &PostFile{Post: post}, nil
+}
@@ -0,0 +1,78 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"git.sr.ht/~erock/lists.sh/pkg"
+	"git.sr.ht/~erock/wish/cms/db"
+	sendutils "git.sr.ht/~erock/wish/send/utils"
+	"github.com/gliderlabs/ssh"
+)
+
+// parsePostArgs reads the name and flags of the `post` command.  Flags can
+// come before or after the name.
+func parsePostArgs(args []string) (string, *WriteOptions, error) {
+	name := ""
+	opts := &WriteOptions{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--hidden":
+			opts.Hidden = true
+		case arg == "--publish-at" || strings.HasPrefix(arg, "--publish-at="):
+			date := strings.TrimPrefix(arg, "--publish-at=")
+			if arg == "--publish-at" {
+				if i+1 >= len(args) {
+					return "", nil, fmt.Errorf("--publish-at needs a date, format must be YYYY-MM-DD")
+				}
+				i++
+				date = args[i]
+			}
+			publishAt, err := pkg.PublishAtDate(date)
+			if err != nil {
+				return "", nil, fmt.Errorf("invalid publish_at date (%s), format must be YYYY-MM-DD", date)
+			}
+			opts.PublishAt = publishAt
+		case strings.HasPrefix(arg, "-"):
+			return "", nil, fmt.Errorf("unknown flag %s", arg)
+		case name != "":
+			return "", nil, fmt.Errorf("only one post can be published at a time")
+		default:
+			name = SanitizeFileExt(arg)
+		}
+	}
+	return name, opts, nil
+}
+
+// Publish saves the text sent over stdin as a post, the same way as if it
+// was uploaded with scp.
+func (h *DbHandler) Publish(s ssh.Session, user *db.User, args []string) (string, error) {
+	name, opts, err := parsePostArgs(args)
+	if err != nil {
+		return "", err
+	}
+	if name == "" {
+		return "", fmt.Errorf("usage: ssh %s post <name> [--hidden] [--publish-at YYYY-MM-DD]", h.Cfg.Domain)
+	}
+
+	b, err := io.ReadAll(s)
+	if err != nil {
+		return "", err
+	}
+
+	// an empty upload removes a post, which is never what a pipe meant to do
+	if len(b) == 0 {
+		return "", fmt.Errorf("nothing was sent, try: cat %s.txt | ssh %s post %s", name, h.Cfg.Domain, name)
+	}
+
+	filename := fmt.Sprintf("%s.txt", name)
+	return h.WritePost(user, &sendutils.FileEntry{
+		Name:     filename,
+		Filepath: filename,
+		Size:     int64(len(b)),
+		Reader:   bytes.NewReader(b),
+	}, opts)
+}
@@ -7,7 +7,7 @@ import (
 	"regexp"
 	"strings"
 
-	"git.sr.ht/~erock/wish/cms/db"
+	"git.sr.ht/~erock/lists.sh/internal/storage"
 	"go.uber.org/zap"
 )
 
@@ -25,22 +25,72 @@ func NewRoute(method, pattern string, handler http.HandlerFunc) Route {
 	}
 }
 
+// CheckReservedRoute errors when a fixed route would shadow the blog of a
+// user whose name is not in storage.ReservedNames.
+func CheckReservedRoute(pattern string) error {
+	path := strings.TrimSuffix(strings.TrimPrefix(pattern, "^/"), "$")
+	name := strings.Split(path, "/")[0]
+	// patterns and names with a dot in them can never be a username
+	if name == "" || regexp.QuoteMeta(name) != name {
+		return nil
+	}
+
+	if !storage.IsReservedName(name) {
+		return fmt.Errorf("route %s shadows username %q, add it to the reserved names", pattern, name)
+	}
+	return nil
+}
+
+// CheckReservedFilenameRoute errors when a fixed route would shadow a post.
+// Posts come right after the username or, on subdomains, at the root.
+func CheckReservedFilenameRoute(pattern string, subdomain bool) error {
+	path := strings.TrimSuffix(strings.TrimPrefix(pattern, "^/"), "$")
+	if !subdomain {
+		if !strings.HasPrefix(path, "([^/]+)/") {
+			return nil
+		}
+		path = strings.TrimPrefix(path, "([^/]+)/")
+	}
+
+	name := strings.Split(path, "/")[0]
+	if name == "" || regexp.QuoteMeta(name) != name {
+		return nil
+	}
+
+	if !IsReservedFilename(name) {
+		return fmt.Errorf("route %s shadows post %q, add it to the reserved filenames", pattern, name)
+	}
+	return nil
+}
+
 type ServeFn func(http.ResponseWriter, *http.Request)
 
-func CreateServe(routes []Route, subdomainRoutes []Route, cfg *ConfigSite, dbpool db.DB, logger *zap.SugaredLogger) ServeFn {
+func CreateServe(routes []Route, subdomainRoutes []Route, cfg *ConfigSite, dbpool storage.DB, logger *zap.SugaredLogger) ServeFn {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var allow []string
 		curRoutes := routes
 
-		hostDomain := strings.ToLower(strings.Split(r.Host, ":")[0])
-		appDomain := strings.ToLower(strings.Split(cfg.ConfigCms.Domain, ":")[0])
+		host := cfg.MatchHost(r.Host)
+		if host.Kind == HostRedirect {
+			target := fmt.Sprintf("%s://%s%s", cfg.Protocol, host.Redirect, r.URL.RequestURI())
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+			return
+		}
 
-		subdomain := ""
-		if hostDomain != appDomain && strings.Contains(hostDomain, appDomain) {
-			subdomain = strings.TrimSuffix(hostDomain, fmt.Sprintf(".%s", appDomain))
+		subdomain := host.Subdomain
+		if host.Kind == HostCustom {
+			// verified custom domains are served like the owner's subdomain
+			user, err := dbpool.FindUserForDomain(host.Name)
+			if err != nil {
+				logger.Infof("unknown host: %s", host.Name)
+				target := fmt.Sprintf("%s://%s%s", cfg.Protocol, cfg.Domain, r.URL.RequestURI())
+				http.Redirect(w, r, target, http.StatusMovedPermanently)
+				return
+			}
+			subdomain = user.Name
 		}
 
-		if cfg.IsSubdomains() && subdomain != "" {
+		if subdomain != "" {
 			curRoutes = subdomainRoutes
 		}
 
@@ -83,8 +133,8 @@ func GetLogger(r *http.Request) *zap.SugaredLogger {
 	return r.Context().Value(ctxLoggerKey{}).(*zap.SugaredLogger)
 }
 
-func GetDB(r *http.Request) db.DB {
-	return r.Context().Value(ctxDBKey{}).(db.DB)
+func GetDB(r *http.Request) storage.DB {
+	return r.Context().Value(ctxDBKey{}).(storage.DB)
 }
 
 func GetField(r *http.Request, index int) string {
@@ -0,0 +1,81 @@
+package internal
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"git.sr.ht/~erock/wish/cms/config"
+	"git.sr.ht/~erock/wish/cms/db"
+	"go.uber.org/zap"
+)
+
+type atomEntry struct {
+	ID    string `xml:"id"`
+	Title string `xml:"title"`
+	Link  struct {
+		Href string `xml:"href,attr"`
+	} `xml:"link"`
+	Content string `xml:"content"`
+}
+
+type atomFeed struct {
+	Entries []atomEntry `xml:"entry"`
+}
+
+func TestRssFeedsShareItems(t *testing.T) {
+	user := &db.User{ID: "user-1", Name: "erock"}
+	dbpool := newMemDB(user)
+	publishAt := time.Date(2022, 8, 1, 0, 0, 0, 0, time.UTC)
+	_, err := dbpool.InsertPost(user.ID, "grocery-list", "grocery-list", "milk\neggs\n", "", &publishAt, false, "lists")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = dbpool.InsertPost(user.ID, "_header", "_header", "=: title my lists\n", "", &publishAt, true, "lists")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := zap.NewNop().Sugar()
+	cfg := &ConfigSite{
+		ConfigCms: config.ConfigCms{Domain: "lists.sh", Protocol: "https", Space: "lists", Logger: logger},
+	}
+	routes := []Route{
+		NewRoute("GET", "/rss", rssHandler),
+		NewRoute("GET", "/([^/]+)/rss", rssBlogHandler),
+	}
+	serve := CreateServe(routes, []Route{}, cfg, dbpool, logger)
+
+	fetch := func(path string) atomFeed {
+		t.Helper()
+		req := httptest.NewRequest("GET", path, nil)
+		req.Host = "lists.sh"
+		rec := httptest.NewRecorder()
+		serve(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s returned %d: %s", path, rec.Code, rec.Body.String())
+		}
+
+		feed := atomFeed{}
+		err := xml.Unmarshal(rec.Body.Bytes(), &feed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return feed
+	}
+
+	discovery := fetch("/rss")
+	blog := fetch("/erock/rss")
+	if len(blog.Entries) != 1 {
+		t.Fatalf("got %d blog entries, want the hidden header left out", len(blog.Entries))
+	}
+	if !reflect.DeepEqual(discovery.Entries, blog.Entries) {
+		t.Errorf("feed items differ:\n%+v\n%+v", discovery.Entries, blog.Entries)
+	}
+	if blog.Entries[0].Title != "Grocery list" {
+		t.Errorf("got title %q, want Grocery list", blog.Entries[0].Title)
+	}
+}
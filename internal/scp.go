@@ -0,0 +1,180 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"git.sr.ht/~erock/wish/send/scp"
+	"github.com/charmbracelet/wish"
+	"github.com/gliderlabs/ssh"
+)
+
+type scpArgs struct {
+	download  bool
+	recursive bool
+	preserve  bool
+	paths     []string
+}
+
+// parseScpArgs reads the command the scp client runs on our end, e.g.
+// `scp -p -f -- /hello.txt`.
+func parseScpArgs(args []string) *scpArgs {
+	opts := &scpArgs{}
+	flags := true
+	for _, arg := range args {
+		if flags && arg == "--" {
+			flags = false
+		} else if flags && strings.HasPrefix(arg, "-") && len(arg) > 1 {
+			for _, flag := range arg[1:] {
+				switch flag {
+				case 'f':
+					opts.download = true
+				case 'r':
+					opts.recursive = true
+				case 'p':
+					opts.preserve = true
+				}
+			}
+		} else {
+			opts.paths = append(opts.paths, arg)
+		}
+	}
+	return opts
+}
+
+// scpReadAck waits for the client to confirm the last message.
+func scpReadAck(r io.Reader) error {
+	buf := make([]byte, 1)
+	_, err := io.ReadFull(r, buf)
+	if err != nil {
+		return err
+	}
+	if buf[0] == 0 {
+		return nil
+	}
+
+	msg := []byte{}
+	for {
+		_, err := io.ReadFull(r, buf)
+		if err != nil || buf[0] == '\n' {
+			break
+		}
+		msg = append(msg, buf[0])
+	}
+	return fmt.Errorf("scp: %s", msg)
+}
+
+// scpFindFiles resolves the paths given to scp, a missing post is only a
+// warning so the rest still download.
+func scpFindFiles(s ssh.Session, o *Opener, args *scpArgs) ([]*PostFile, int) {
+	files := []*PostFile{}
+	missing := 0
+	for _, name := range args.paths {
+		var found []*PostFile
+		var err error
+		if IsRootDir(name) {
+			if !args.recursive {
+				fmt.Fprintf(s, "\x01scp: %s: not a regular file\n", name)
+				missing++
+				continue
+			}
+			found, err = o.ReadDir()
+		} else if strings.ContainsAny(name, "*?[") {
+			// the shell of a regular server would have expanded the pattern
+			found, err = o.Glob(name)
+		} else {
+			var file *PostFile
+			file, err = o.Open(name)
+			found = []*PostFile{file}
+		}
+
+		if err != nil || len(found) == 0 {
+			fmt.Fprintf(s, "\x01scp: %s: No such file or directory\n", name)
+			missing++
+			continue
+		}
+		files = append(files, found...)
+	}
+	return files, missing
+}
+
+// scpDownload sends posts to an scp client, which is the source side of the
+// scp protocol.
+func scpDownload(s ssh.Session, o *Opener, args *scpArgs) (int, error) {
+	// the client starts by telling us it is ready
+	err := scpReadAck(s)
+	if err != nil {
+		return 0, err
+	}
+
+	files, missing := scpFindFiles(s, o, args)
+	for _, file := range files {
+		if args.preserve {
+			mtime := file.ModTime().Unix()
+			fmt.Fprintf(s, "T%d 0 %d 0\n", mtime, mtime)
+			err = scpReadAck(s)
+			if err != nil {
+				return missing, err
+			}
+		}
+
+		fmt.Fprintf(s, "C%04o %d %s\n", file.Mode().Perm(), file.Size(), file.Name())
+		err = scpReadAck(s)
+		if err != nil {
+			return missing, err
+		}
+
+		_, err = io.WriteString(s, file.Post.Text)
+		if err != nil {
+			return missing, err
+		}
+		_, err = s.Write([]byte{0})
+		if err != nil {
+			return missing, err
+		}
+		err = scpReadAck(s)
+		if err != nil {
+			return missing, err
+		}
+
+		o.Cfg.Logger.Infof("(%s) downloaded with scp", file.Post.Filename)
+	}
+
+	return missing, nil
+}
+
+// ScpMiddleware sends uploads to the scp middleware of the send library,
+// which only receives files, and serves downloads (`scp -f`) itself.
+func ScpMiddleware(h *DbHandler) wish.Middleware {
+	upload := scp.Middleware(h)
+	return func(sh ssh.Handler) ssh.Handler {
+		uploadHandler := upload(sh)
+		return func(s ssh.Session) {
+			args := parseScpArgs(s.Command()[1:])
+			if !args.download {
+				uploadHandler(s)
+				return
+			}
+
+			o, err := h.NewOpener(s)
+			if err != nil {
+				fmt.Fprintf(s, "\x02scp: %s\n", err)
+				_ = s.Exit(1)
+				return
+			}
+
+			missing, err := scpDownload(s, o, args)
+			if err != nil {
+				o.Cfg.Logger.Error(err)
+				_ = s.Exit(1)
+				return
+			}
+			if missing > 0 {
+				_ = s.Exit(1)
+				return
+			}
+			sh(s)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+
+	sendutils "git.sr.ht/~erock/wish/send/utils"
+	"github.com/gliderlabs/ssh"
+	"github.com/pkg/sftp"
+)
+
+type listerAt []fs.FileInfo
+
+func (l listerAt) ListAt(ls []fs.FileInfo, offset int64) (int, error) {
+	if offset >= int64(len(l)) {
+		return 0, io.EOF
+	}
+
+	n := copy(ls, l[offset:])
+	if n < len(ls) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// MaxUploadSize caps how much of an sftp upload is kept in memory.
+var MaxUploadSize int64 = 10 << 20
+
+// sftpUpload collects a file until the client closes it and then saves it
+// the same way as an scp upload.
+type sftpUpload struct {
+	h        *DbHandler
+	s        ssh.Session
+	filepath string
+	mu       sync.Mutex
+	buf      []byte
+}
+
+func (u *sftpUpload) WriteAt(p []byte, off int64) (int, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if off < 0 {
+		return 0, fmt.Errorf("invalid offset %d", off)
+	}
+	end := off + int64(len(p))
+	if end > MaxUploadSize {
+		return 0, fmt.Errorf("(%s) is larger than %d bytes", path.Base(u.filepath), MaxUploadSize)
+	}
+
+	if end > int64(len(u.buf)) {
+		u.buf = append(u.buf, make([]byte, end-int64(len(u.buf)))...)
+	}
+	return copy(u.buf[off:], p), nil
+}
+
+func (u *sftpUpload) Close() error {
+	err := u.h.Validate(u.s)
+	if err != nil {
+		return err
+	}
+
+	msg, err := u.h.Write(u.s, &sendutils.FileEntry{
+		Name:     path.Base(u.filepath),
+		Filepath: u.filepath,
+		Size:     int64(len(u.buf)),
+		Reader:   bytes.NewReader(u.buf),
+	})
+	if err != nil {
+		return err
+	}
+
+	u.h.Cfg.Logger.Infof("(%s) uploaded with sftp: %s", path.Base(u.filepath), msg)
+	return nil
+}
+
+type sftpHandler struct {
+	h *DbHandler
+	o *Opener
+	s ssh.Session
+}
+
+func (fh *sftpHandler) Fileread(r *sftp.Request) (io.ReaderAt, error) {
+	file, err := fh.o.Open(r.Filepath)
+	if err != nil {
+		return nil, err
+	}
+
+	fh.o.Cfg.Logger.Infof("(%s) downloaded with sftp", file.Post.Filename)
+	return strings.NewReader(file.Post.Text), nil
+}
+
+func (fh *sftpHandler) Filewrite(r *sftp.Request) (io.WriterAt, error) {
+	return &sftpUpload{h: fh.h, s: fh.s, filepath: r.Filepath}, nil
+}
+
+func (fh *sftpHandler) Filecmd(r *sftp.Request) error {
+	// clients set the mtime after an upload, posts keep their own
+	if r.Method == "Setstat" {
+		return nil
+	}
+	return sftp.ErrSSHFxOpUnsupported
+}
+
+func (fh *sftpHandler) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
+	switch r.Method {
+	case "List":
+		if !IsRootDir(r.Filepath) {
+			return nil, fs.ErrNotExist
+		}
+
+		files, err := fh.o.ReadDir()
+		if err != nil {
+			return nil, err
+		}
+
+		list := listerAt{}
+		for _, file := range files {
+			list = append(list, file)
+		}
+		return list, nil
+	case "Stat":
+		if IsRootDir(r.Filepath) {
+			return listerAt{rootDir{}}, nil
+		}
+
+		file, err := fh.o.Open(r.Filepath)
+		if err != nil {
+			return nil, err
+		}
+		return listerAt{file}, nil
+	}
+	return nil, sftp.ErrSSHFxOpUnsupported
+}
+
+// SftpSubsystem serves posts as <filename>.txt files for sftp clients and
+// saves uploads the same way as scp.  OpenSSH 9 runs scp over sftp so both
+// directions have to work here.
+func SftpSubsystem(h *DbHandler) ssh.SubsystemHandler {
+	return func(s ssh.Session) {
+		o, err := h.NewOpener(s)
+		if err != nil {
+			h.Cfg.Logger.Error(err)
+			_ = s.Exit(1)
+			return
+		}
+
+		handler := &sftpHandler{h: h, o: o, s: s}
+		server := sftp.NewRequestServer(s, sftp.Handlers{
+			FileGet:  handler,
+			FilePut:  handler,
+			FileCmd:  handler,
+			FileList: handler,
+		})
+
+		err = server.Serve()
+		if err != nil && !errors.Is(err, io.EOF) {
+			h.Cfg.Logger.Error(err)
+		}
+	}
+}
+
+func SftpOption(h *DbHandler) ssh.Option {
+	return func(server *ssh.Server) error {
+		if server.SubsystemHandlers == nil {
+			server.SubsystemHandlers = map[string]ssh.SubsystemHandler{}
+		}
+		server.SubsystemHandlers["sftp"] = SftpSubsystem(h)
+		return nil
+	}
+}
@@ -0,0 +1,129 @@
+package internal
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"io"
+	"net"
+	"testing"
+
+	"git.sr.ht/~erock/wish/cms/config"
+	"git.sr.ht/~erock/wish/cms/db"
+	"github.com/gliderlabs/ssh"
+	"github.com/pkg/sftp"
+	"go.uber.org/zap"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// pipeSession is an ssh session whose channel is one end of a pipe.
+type pipeSession struct {
+	ssh.Session
+	conn net.Conn
+	key  ssh.PublicKey
+}
+
+func (s *pipeSession) Read(p []byte) (int, error)  { return s.conn.Read(p) }
+func (s *pipeSession) Write(p []byte) (int, error) { return s.conn.Write(p) }
+func (s *pipeSession) Close() error                { return s.conn.Close() }
+func (s *pipeSession) Exit(code int) error         { return s.conn.Close() }
+func (s *pipeSession) User() string                { return "erock" }
+func (s *pipeSession) PublicKey() ssh.PublicKey    { return s.key }
+
+func newSftpClient(t *testing.T, h *DbHandler) *sftp.Client {
+	t.Helper()
+
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := gossh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serverConn, clientConn := net.Pipe()
+	go SftpSubsystem(h)(&pipeSession{conn: serverConn, key: key})
+
+	client, err := sftp.NewClientPipe(clientConn, clientConn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+func TestSftpUploadAndDownload(t *testing.T) {
+	user := &db.User{ID: "user-1", Name: "erock"}
+	dbpool := newMemDB(user)
+	cfg := &ConfigSite{
+		ConfigCms: config.ConfigCms{
+			Domain:   "lists.sh",
+			Protocol: "https",
+			Space:    "lists",
+			Logger:   zap.NewNop().Sugar(),
+		},
+	}
+	client := newSftpClient(t, NewDbHandler(dbpool, cfg))
+
+	text := "=: title groceries\nmilk\neggs\n"
+	w, err := client.Create("/groceries.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = w.Write([]byte(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	post, ok := dbpool.posts["groceries"]
+	if !ok {
+		t.Fatal("upload did not create the post")
+	}
+	if post.Title != "groceries" {
+		t.Errorf("got title %q, want groceries", post.Title)
+	}
+
+	files, err := client.ReadDir("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Name() != "groceries.txt" {
+		t.Fatalf("got %v, want only groceries.txt", files)
+	}
+
+	r, err := client.Open("/groceries.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != text {
+		t.Errorf("downloaded %q, want %q", b, text)
+	}
+}
+
+func TestSftpUploadRejectsInvalidWrites(t *testing.T) {
+	upload := &sftpUpload{filepath: "/big.txt"}
+
+	_, err := upload.WriteAt([]byte("milk"), -1)
+	if err == nil {
+		t.Error("expected a negative offset to be rejected")
+	}
+
+	_, err = upload.WriteAt([]byte("milk"), MaxUploadSize)
+	if err == nil {
+		t.Error("expected a write past the max upload size to be rejected")
+	}
+
+	if len(upload.buf) != 0 {
+		t.Errorf("rejected writes grew the buffer to %d bytes", len(upload.buf))
+	}
+}
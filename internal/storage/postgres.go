@@ -0,0 +1,659 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	"git.sr.ht/~erock/wish/cms/config"
+	"git.sr.ht/~erock/wish/cms/db"
+	"git.sr.ht/~erock/wish/cms/db/postgres"
+)
+
+const (
+	sqlSelectPostDataForPosts = `SELECT id, data FROM posts WHERE id = ANY(string_to_array($1, ',')::uuid[])`
+	sqlUpdatePostData         = `UPDATE posts SET data = $1 WHERE id = $2`
+	sqlUpdatePostHidden       = `UPDATE posts SET hidden = $1 WHERE id = $2`
+	sqlSelectPostsWithoutData = `
+	SELECT posts.id, user_id, filename, title, text, description, publish_at, app_users.name as username, posts.updated_at
+	FROM posts
+	LEFT OUTER JOIN app_users ON app_users.id = posts.user_id
+	WHERE data = '{}'::jsonb AND cur_space = $1`
+
+	sqlDeleteTagsForPost = `DELETE FROM post_tags WHERE post_id = $1`
+	sqlInsertTagForPost  = `INSERT INTO post_tags (post_id, name) VALUES ($1, $2)`
+
+	sqlSelectUserPostsByTag = `
+	SELECT posts.id, user_id, filename, title, text, description, publish_at, app_users.name as username, posts.updated_at
+	FROM posts
+	LEFT OUTER JOIN app_users ON app_users.id = posts.user_id
+	WHERE
+		posts.user_id = $1 AND
+		cur_space = $3 AND
+		hidden = FALSE AND
+		publish_at::date <= CURRENT_DATE AND
+		EXISTS (SELECT 1 FROM post_tags WHERE post_tags.post_id = posts.id AND post_tags.name = $2)
+	ORDER BY publish_at DESC`
+	sqlSelectPostsByTag = `
+	SELECT posts.id, user_id, filename, title, text, description, publish_at, app_users.name as username, posts.updated_at
+	FROM posts
+	LEFT OUTER JOIN app_users ON app_users.id = posts.user_id
+	WHERE
+		cur_space = $4 AND
+		hidden = FALSE AND
+		publish_at::date <= CURRENT_DATE AND
+		EXISTS (SELECT 1 FROM post_tags WHERE post_tags.post_id = posts.id AND post_tags.name = $1)
+	ORDER BY publish_at DESC
+	LIMIT $2 OFFSET $3`
+	sqlSelectPostsByTagCount = `
+	SELECT count(posts.id)
+	FROM posts
+	WHERE
+		cur_space = $2 AND
+		hidden = FALSE AND
+		publish_at::date <= CURRENT_DATE AND
+		EXISTS (SELECT 1 FROM post_tags WHERE post_tags.post_id = posts.id AND post_tags.name = $1)`
+
+	// headlines are only generated for the requested page of results since
+	// they are expensive to compute
+	sqlSelectPostsBySearch = `
+	SELECT id, user_id, filename, title, text, description, publish_at, username, updated_at, rank,
+		ts_headline('english', text, websearch_to_tsquery('english', $1), $2) AS snippet
+	FROM (
+		SELECT posts.id, user_id, filename, title, text, description, publish_at, app_users.name as username, posts.updated_at,
+			ts_rank(search_vector, websearch_to_tsquery('english', $1)) AS rank
+		FROM posts
+		LEFT OUTER JOIN app_users ON app_users.id = posts.user_id
+		WHERE
+			cur_space = $5 AND
+			hidden = FALSE AND
+			publish_at::date <= CURRENT_DATE AND
+			search_vector @@ websearch_to_tsquery('english', $1)
+		ORDER BY rank DESC, publish_at DESC
+		LIMIT $3 OFFSET $4
+	) AS results
+	ORDER BY rank DESC, publish_at DESC`
+	sqlSelectPostsBySearchCount = `
+	SELECT count(id)
+	FROM posts
+	WHERE
+		cur_space = $2 AND
+		hidden = FALSE AND
+		publish_at::date <= CURRENT_DATE AND
+		search_vector @@ websearch_to_tsquery('english', $1)`
+	sqlSelectUserPostsBySearch = `
+	SELECT id, user_id, filename, title, text, description, publish_at, username, updated_at, rank,
+		ts_headline('english', text, websearch_to_tsquery('english', $1), $2) AS snippet
+	FROM (
+		SELECT posts.id, user_id, filename, title, text, description, publish_at, app_users.name as username, posts.updated_at,
+			ts_rank(search_vector, websearch_to_tsquery('english', $1)) AS rank
+		FROM posts
+		LEFT OUTER JOIN app_users ON app_users.id = posts.user_id
+		WHERE
+			posts.user_id = $5 AND
+			cur_space = $6 AND
+			hidden = FALSE AND
+			publish_at::date <= CURRENT_DATE AND
+			search_vector @@ websearch_to_tsquery('english', $1)
+		ORDER BY rank DESC, publish_at DESC
+		LIMIT $3 OFFSET $4
+	) AS results
+	ORDER BY rank DESC, publish_at DESC`
+	sqlSelectUserPostsBySearchCount = `
+	SELECT count(id)
+	FROM posts
+	WHERE
+		user_id = $2 AND
+		cur_space = $3 AND
+		hidden = FALSE AND
+		publish_at::date <= CURRENT_DATE AND
+		search_vector @@ websearch_to_tsquery('english', $1)`
+
+	sqlSelectDomainForUser = `SELECT id, user_id, domain, verified_at FROM user_domains WHERE user_id = $1`
+	sqlSelectUserForDomain = `
+	SELECT app_users.id, app_users.name, app_users.created_at
+	FROM user_domains
+	INNER JOIN app_users ON app_users.id = user_domains.user_id
+	WHERE user_domains.domain = $1 AND user_domains.verified_at IS NOT NULL`
+	sqlUpsertDomainForUser = `
+	INSERT INTO user_domains (user_id, domain) VALUES ($1, $2)
+	ON CONFLICT (user_id) DO UPDATE SET domain = $2, verified_at = NULL
+	RETURNING id, user_id, domain, verified_at`
+	sqlUpdateDomainVerified = `UPDATE user_domains SET verified_at = NOW() WHERE id = $1`
+	sqlDeleteDomainForUser  = `DELETE FROM user_domains WHERE user_id = $1`
+
+	sqlInsertReservedNames = `
+	INSERT INTO reserved_usernames (space, name)
+	SELECT $1, unnest(string_to_array($2, ','))
+	ON CONFLICT DO NOTHING`
+	sqlDeleteUnreservedNames = `
+	DELETE FROM reserved_usernames
+	WHERE space = $1 AND name <> ALL(string_to_array($2, ','))`
+
+	sqlInsertRevision = `
+	INSERT INTO post_revisions (post_id, title, text) VALUES ($1, $2, $3)
+	RETURNING id, post_id, title, text, created_at`
+	sqlSelectRevisionsForPost = `
+	SELECT id, post_id, title, text, created_at
+	FROM post_revisions
+	WHERE post_id = $1
+	ORDER BY created_at DESC
+	LIMIT $2`
+	sqlSelectRevisionCountForPost = `SELECT count(id) FROM post_revisions WHERE post_id = $1`
+	sqlSelectRevisionsWithPrefix  = `
+	SELECT id, post_id, title, text, created_at
+	FROM post_revisions
+	WHERE post_id = $1 AND id::text LIKE $2 || '%'
+	ORDER BY created_at DESC
+	LIMIT $3`
+	sqlSelectRevision = `
+	SELECT id, post_id, title, text, created_at
+	FROM post_revisions
+	WHERE post_id = $1 AND id = $2`
+
+	sqlSelectPostViewsForUser = `
+	SELECT posts.id, filename, title, publish_at, posts.updated_at, COALESCE(SUM(post_analytics.views), 0) AS views
+	FROM posts
+	LEFT OUTER JOIN post_analytics ON post_analytics.post_id = posts.id
+	WHERE posts.user_id = $1 AND cur_space = $2
+	GROUP BY posts.id
+	ORDER BY views DESC, publish_at DESC`
+
+	// the previous text is looked up before filtering so the oldest change
+	// in the page still has something to diff against
+	sqlSelectChangesForPost = `
+	SELECT id, post_id, title, text, created_at, filename, username, previous_text
+	FROM (
+		SELECT post_revisions.id, post_id, post_revisions.title, post_revisions.text, post_revisions.created_at,
+			posts.filename, app_users.name as username,
+			COALESCE(LAG(post_revisions.text) OVER (PARTITION BY post_id ORDER BY post_revisions.created_at), '') AS previous_text
+		FROM post_revisions
+		INNER JOIN posts ON posts.id = post_revisions.post_id
+		LEFT OUTER JOIN app_users ON app_users.id = posts.user_id
+		WHERE post_id = $1
+	) AS changes
+	ORDER BY created_at DESC
+	LIMIT $2`
+	sqlSelectChangesForUser = `
+	SELECT id, post_id, title, text, created_at, filename, username, previous_text
+	FROM (
+		SELECT post_revisions.id, post_id, post_revisions.title, post_revisions.text, post_revisions.created_at,
+			posts.filename, app_users.name as username,
+			COALESCE(LAG(post_revisions.text) OVER (PARTITION BY post_id ORDER BY post_revisions.created_at), '') AS previous_text
+		FROM post_revisions
+		INNER JOIN posts ON posts.id = post_revisions.post_id
+		LEFT OUTER JOIN app_users ON app_users.id = posts.user_id
+		WHERE
+			posts.user_id = $1 AND
+			cur_space = $3 AND
+			hidden = FALSE AND
+			publish_at::date <= CURRENT_DATE
+	) AS changes
+	ORDER BY created_at DESC
+	LIMIT $2`
+)
+
+var headlineOpts = fmt.Sprintf(
+	`StartSel="%s", StopSel="%s", MaxWords=25, MinWords=10, MaxFragments=2, FragmentDelimiter=" ... "`,
+	SnippetStart,
+	SnippetStop,
+)
+
+type PsqlDB struct {
+	*postgres.PsqlDB
+}
+
+func NewDB(cfg *config.ConfigCms) *PsqlDB {
+	return &PsqlDB{
+		PsqlDB: postgres.NewDB(cfg),
+	}
+}
+
+func postsFromRows(rs *sql.Rows) ([]*db.Post, error) {
+	posts := []*db.Post{}
+	for rs.Next() {
+		post := &db.Post{}
+		err := rs.Scan(
+			&post.ID,
+			&post.UserID,
+			&post.Filename,
+			&post.Title,
+			&post.Text,
+			&post.Description,
+			&post.PublishAt,
+			&post.Username,
+			&post.UpdatedAt,
+		)
+		if err != nil {
+			return posts, err
+		}
+
+		posts = append(posts, post)
+	}
+
+	return posts, rs.Err()
+}
+
+// FindPostDataForPosts loads the metadata persisted for many posts at once,
+// keyed by post id.
+func (me *PsqlDB) FindPostDataForPosts(postIDs []string) (map[string]*PostData, error) {
+	found := map[string]*PostData{}
+	if len(postIDs) == 0 {
+		return found, nil
+	}
+
+	rs, err := me.Db.Query(sqlSelectPostDataForPosts, strings.Join(postIDs, ","))
+	if err != nil {
+		return found, err
+	}
+	defer rs.Close()
+
+	for rs.Next() {
+		var id string
+		var raw []byte
+		err := rs.Scan(&id, &raw)
+		if err != nil {
+			return found, err
+		}
+
+		data := &PostData{}
+		err = json.Unmarshal(raw, data)
+		if err != nil {
+			return found, err
+		}
+		found[id] = data
+	}
+
+	return found, rs.Err()
+}
+
+// FindPostsWithoutData returns the posts uploaded before their metadata was
+// persisted.
+func (me *PsqlDB) FindPostsWithoutData(space string) ([]*db.Post, error) {
+	rs, err := me.Db.Query(sqlSelectPostsWithoutData, space)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+
+	return postsFromRows(rs)
+}
+
+func (me *PsqlDB) UpdatePostData(postID string, data *PostData) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = me.Db.Exec(sqlUpdatePostData, raw, postID)
+	return err
+}
+
+func (me *PsqlDB) SetPostHidden(postID string, hidden bool) error {
+	_, err := me.Db.Exec(sqlUpdatePostHidden, hidden, postID)
+	return err
+}
+
+func (me *PsqlDB) ReplaceTagsForPost(tags []string, postID string) error {
+	tx, err := me.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	_, err = tx.Exec(sqlDeleteTagsForPost, postID)
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		_, err = tx.Exec(sqlInsertTagForPost, postID, tag)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (me *PsqlDB) FindUserPostsByTag(tag string, userID string, space string) ([]*db.Post, error) {
+	rs, err := me.Db.Query(sqlSelectUserPostsByTag, userID, tag, space)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+
+	return postsFromRows(rs)
+}
+
+func (me *PsqlDB) FindPostsByTag(pager *db.Pager, tag string, space string) (*db.Paginate[*db.Post], error) {
+	rs, err := me.Db.Query(sqlSelectPostsByTag, tag, pager.Num, pager.Num*pager.Page, space)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+
+	posts, err := postsFromRows(rs)
+	if err != nil {
+		return nil, err
+	}
+
+	var count int
+	err = me.Db.QueryRow(sqlSelectPostsByTagCount, tag, space).Scan(&count)
+	if err != nil {
+		return nil, err
+	}
+
+	return &db.Paginate[*db.Post]{
+		Data:  posts,
+		Total: int(math.Ceil(float64(count) / float64(pager.Num))),
+	}, nil
+}
+
+func searchResultsFromRows(rs *sql.Rows) ([]*SearchResult, error) {
+	results := []*SearchResult{}
+	for rs.Next() {
+		result := &SearchResult{Post: &db.Post{}}
+		err := rs.Scan(
+			&result.ID,
+			&result.UserID,
+			&result.Filename,
+			&result.Title,
+			&result.Text,
+			&result.Description,
+			&result.PublishAt,
+			&result.Username,
+			&result.UpdatedAt,
+			&result.Rank,
+			&result.Snippet,
+		)
+		if err != nil {
+			return results, err
+		}
+
+		results = append(results, result)
+	}
+
+	return results, rs.Err()
+}
+
+func (me *PsqlDB) FindPostsBySearch(pager *db.Pager, query string, space string) (*db.Paginate[*SearchResult], error) {
+	rs, err := me.Db.Query(sqlSelectPostsBySearch, query, headlineOpts, pager.Num, pager.Num*pager.Page, space)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+
+	results, err := searchResultsFromRows(rs)
+	if err != nil {
+		return nil, err
+	}
+
+	var count int
+	err = me.Db.QueryRow(sqlSelectPostsBySearchCount, query, space).Scan(&count)
+	if err != nil {
+		return nil, err
+	}
+
+	return &db.Paginate[*SearchResult]{
+		Data:  results,
+		Total: int(math.Ceil(float64(count) / float64(pager.Num))),
+	}, nil
+}
+
+func (me *PsqlDB) FindUserPostsBySearch(pager *db.Pager, query string, userID string, space string) (*db.Paginate[*SearchResult], error) {
+	rs, err := me.Db.Query(sqlSelectUserPostsBySearch, query, headlineOpts, pager.Num, pager.Num*pager.Page, userID, space)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+
+	results, err := searchResultsFromRows(rs)
+	if err != nil {
+		return nil, err
+	}
+
+	var count int
+	err = me.Db.QueryRow(sqlSelectUserPostsBySearchCount, query, userID, space).Scan(&count)
+	if err != nil {
+		return nil, err
+	}
+
+	return &db.Paginate[*SearchResult]{
+		Data:  results,
+		Total: int(math.Ceil(float64(count) / float64(pager.Num))),
+	}, nil
+}
+
+func (me *PsqlDB) FindDomainForUser(userID string) (*Domain, error) {
+	domain := &Domain{}
+	err := me.Db.QueryRow(sqlSelectDomainForUser, userID).Scan(
+		&domain.ID,
+		&domain.UserID,
+		&domain.Domain,
+		&domain.VerifiedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return domain, nil
+}
+
+func (me *PsqlDB) FindUserForDomain(domain string) (*db.User, error) {
+	user := &db.User{}
+	err := me.Db.QueryRow(sqlSelectUserForDomain, domain).Scan(&user.ID, &user.Name, &user.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (me *PsqlDB) SetDomainForUser(userID string, domain string) (*Domain, error) {
+	d := &Domain{}
+	err := me.Db.QueryRow(sqlUpsertDomainForUser, userID, domain).Scan(
+		&d.ID,
+		&d.UserID,
+		&d.Domain,
+		&d.VerifiedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (me *PsqlDB) VerifyDomain(domainID string) error {
+	_, err := me.Db.Exec(sqlUpdateDomainVerified, domainID)
+	return err
+}
+
+func (me *PsqlDB) RemoveDomainForUser(userID string) error {
+	_, err := me.Db.Exec(sqlDeleteDomainForUser, userID)
+	return err
+}
+
+// SyncReservedNames mirrors the names into the table the database uses to
+// reject them, which also covers signups that go around this package.  Names
+// are added before the ones no longer reserved are removed so the table is
+// never empty, even for another replica starting at the same time.
+func (me *PsqlDB) SyncReservedNames(space string, names []string) error {
+	list := strings.Join(names, ",")
+	tx, err := me.Db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	_, err = tx.Exec(sqlInsertReservedNames, space, list)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(sqlDeleteUnreservedNames, space, list)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (me *PsqlDB) InsertRevision(postID string, title string, text string) (*Revision, error) {
+	rev := &Revision{}
+	err := me.Db.QueryRow(sqlInsertRevision, postID, title, text).Scan(
+		&rev.ID,
+		&rev.PostID,
+		&rev.Title,
+		&rev.Text,
+		&rev.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return rev, nil
+}
+
+// FindRevisionsForPost returns the latest revisions of a post, newest first.
+func (me *PsqlDB) FindRevisionsForPost(postID string, limit int) ([]*Revision, error) {
+	rs, err := me.Db.Query(sqlSelectRevisionsForPost, postID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+	return revisionsFromRows(rs)
+}
+
+func (me *PsqlDB) CountRevisionsForPost(postID string) (int, error) {
+	count := 0
+	err := me.Db.QueryRow(sqlSelectRevisionCountForPost, postID).Scan(&count)
+	return count, err
+}
+
+// FindRevisionsWithPrefix returns the revisions whose id starts with the
+// prefix, newest first.  The prefix must only contain characters of a uuid.
+func (me *PsqlDB) FindRevisionsWithPrefix(postID string, prefix string, limit int) ([]*Revision, error) {
+	rs, err := me.Db.Query(sqlSelectRevisionsWithPrefix, postID, prefix, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+	return revisionsFromRows(rs)
+}
+
+func revisionsFromRows(rs *sql.Rows) ([]*Revision, error) {
+	revs := []*Revision{}
+	for rs.Next() {
+		rev := &Revision{}
+		err := rs.Scan(
+			&rev.ID,
+			&rev.PostID,
+			&rev.Title,
+			&rev.Text,
+			&rev.CreatedAt,
+		)
+		if err != nil {
+			return revs, err
+		}
+
+		revs = append(revs, rev)
+	}
+
+	return revs, rs.Err()
+}
+
+func (me *PsqlDB) FindRevision(postID string, revisionID string) (*Revision, error) {
+	rev := &Revision{}
+	err := me.Db.QueryRow(sqlSelectRevision, postID, revisionID).Scan(
+		&rev.ID,
+		&rev.PostID,
+		&rev.Title,
+		&rev.Text,
+		&rev.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return rev, nil
+}
+
+func changesFromRows(rs *sql.Rows) ([]*Change, error) {
+	changes := []*Change{}
+	for rs.Next() {
+		change := &Change{Revision: &Revision{}}
+		err := rs.Scan(
+			&change.ID,
+			&change.PostID,
+			&change.Title,
+			&change.Text,
+			&change.CreatedAt,
+			&change.Filename,
+			&change.Username,
+			&change.PreviousText,
+		)
+		if err != nil {
+			return changes, err
+		}
+
+		changes = append(changes, change)
+	}
+
+	return changes, rs.Err()
+}
+
+// FindChangesForPost returns the latest revisions of a post, newest first.
+func (me *PsqlDB) FindChangesForPost(postID string, limit int) ([]*Change, error) {
+	rs, err := me.Db.Query(sqlSelectChangesForPost, postID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+
+	return changesFromRows(rs)
+}
+
+// FindChangesForUser returns the latest revisions across the published
+// posts of a user, newest first.
+func (me *PsqlDB) FindChangesForUser(userID string, limit int, space string) ([]*Change, error) {
+	rs, err := me.Db.Query(sqlSelectChangesForUser, userID, limit, space)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+
+	return changesFromRows(rs)
+}
+
+// FindPostViewsForUser returns every post of a user with its view count,
+// most viewed first.  The text of the posts is not loaded.
+func (me *PsqlDB) FindPostViewsForUser(userID string, space string) ([]*db.Post, error) {
+	rs, err := me.Db.Query(sqlSelectPostViewsForUser, userID, space)
+	if err != nil {
+		return nil, err
+	}
+	defer rs.Close()
+
+	posts := []*db.Post{}
+	for rs.Next() {
+		post := &db.Post{UserID: userID}
+		err := rs.Scan(
+			&post.ID,
+			&post.Filename,
+			&post.Title,
+			&post.PublishAt,
+			&post.UpdatedAt,
+			&post.Views,
+		)
+		if err != nil {
+			return posts, err
+		}
+
+		posts = append(posts, post)
+	}
+
+	return posts, rs.Err()
+}
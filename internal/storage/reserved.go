@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// ReservedNames can never be usernames because they are, or could become,
+// routes on the site or subdomains we need for ourselves.  The web and
+// gemini routers check that every fixed route is listed here.
+var ReservedNames = []string{
+	"about",
+	"abuse",
+	"admin",
+	"administrator",
+	"api",
+	"app",
+	"assets",
+	"atom",
+	"blog",
+	"check",
+	"feed",
+	"help",
+	"hostmaster",
+	"lists",
+	"mail",
+	"new",
+	"ops",
+	"postmaster",
+	"privacy",
+	"public",
+	"raw",
+	"read",
+	"root",
+	"rss",
+	"search",
+	"security",
+	"spec",
+	"static",
+	"status",
+	"support",
+	"tags",
+	"transparency",
+	"webmaster",
+	"www",
+}
+
+func IsReservedName(name string) bool {
+	return slices.Contains(ReservedNames, strings.ToLower(name))
+}
@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"time"
+
+	"git.sr.ht/~erock/wish/cms/db"
+)
+
+// PostData is the metadata we extract from a post's text and persist
+// alongside it.
+type PostData struct {
+	Tags  []string          `json:"tags"`
+	Extra map[string]string `json:"extra"`
+}
+
+// SnippetStart and SnippetStop surround every search match inside
+// SearchResult.Snippet.
+var SnippetStart = "\x02"
+var SnippetStop = "\x03"
+
+type SearchResult struct {
+	*db.Post
+	Rank    float64
+	Snippet string
+}
+
+// Domain is a custom domain a user wants their blog served from.  It is
+// only routed once VerifiedAt is set.
+type Domain struct {
+	ID         string
+	UserID     string
+	Domain     string
+	VerifiedAt *time.Time
+}
+
+// Revision is the text of a post at the time it was uploaded.  The newest
+// revision of a post matches its current text.
+type Revision struct {
+	ID        string
+	PostID    string
+	Title     string
+	Text      string
+	CreatedAt *time.Time
+}
+
+// Change is a revision along with the text it replaced, which is empty for
+// the first upload of a post.
+type Change struct {
+	*Revision
+	Filename     string
+	Username     string
+	PreviousText string
+}
+
+// DB extends the cms database with the queries that only lists needs.
+type DB interface {
+	db.DB
+
+	FindPostDataForPosts(postIDs []string) (map[string]*PostData, error)
+	FindPostsWithoutData(space string) ([]*db.Post, error)
+	UpdatePostData(postID string, data *PostData) error
+	SetPostHidden(postID string, hidden bool) error
+
+	ReplaceTagsForPost(tags []string, postID string) error
+	FindUserPostsByTag(tag string, userID string, space string) ([]*db.Post, error)
+	FindPostsByTag(pager *db.Pager, tag string, space string) (*db.Paginate[*db.Post], error)
+
+	FindPostsBySearch(pager *db.Pager, query string, space string) (*db.Paginate[*SearchResult], error)
+	FindUserPostsBySearch(pager *db.Pager, query string, userID string, space string) (*db.Paginate[*SearchResult], error)
+
+	FindDomainForUser(userID string) (*Domain, error)
+	FindUserForDomain(domain string) (*db.User, error)
+	SetDomainForUser(userID string, domain string) (*Domain, error)
+	VerifyDomain(domainID string) error
+	RemoveDomainForUser(userID string) error
+
+	SyncReservedNames(space string, names []string) error
+
+	InsertRevision(postID string, title string, text string) (*Revision, error)
+	FindRevisionsForPost(postID string, limit int) ([]*Revision, error)
+	CountRevisionsForPost(postID string) (int, error)
+	FindRevisionsWithPrefix(postID string, prefix string, limit int) ([]*Revision, error)
+	FindRevision(postID string, revisionID string) (*Revision, error)
+	FindChangesForPost(postID string, limit int) ([]*Change, error)
+	FindChangesForUser(userID string, limit int, space string) ([]*Change, error)
+
+	FindPostViewsForUser(userID string, space string) ([]*db.Post, error)
+}
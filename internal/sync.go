@@ -0,0 +1,156 @@
+package internal
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"git.sr.ht/~erock/wish/cms/db"
+	"github.com/gliderlabs/ssh"
+	"golang.org/x/exp/slices"
+)
+
+var checksumRe = regexp.MustCompile(`^[a-f0-9]{64}$`)
+
+// ParseManifest reads the files in a local blog folder, one per line.  A
+// line can be a bare path or the output of `sha256sum`, in which case the
+// checksum is used to find posts that changed.  The result maps a post
+// filename to its checksum, which is empty when it was not given.
+func ParseManifest(r io.Reader) (map[string]string, error) {
+	manifest := map[string]string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		checksum := ""
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && checksumRe.MatchString(fields[0]) {
+			checksum = fields[0]
+			line = strings.TrimPrefix(strings.TrimSpace(strings.TrimPrefix(line, checksum)), "*")
+		}
+
+		if filepath.Ext(line) != ".txt" {
+			continue
+		}
+		manifest[SanitizeFileExt(filepath.Base(line))] = checksum
+	}
+
+	return manifest, scanner.Err()
+}
+
+// SyncPlan is the difference between a local blog folder and the published
+// posts.
+type SyncPlan struct {
+	Add       []string
+	Update    []string
+	Unchanged []string
+	Remove    []*db.Post
+}
+
+// PlanSync compares a manifest with the published posts.  Posts can only be
+// marked as updated when the manifest has checksums.
+func PlanSync(manifest map[string]string, posts []*db.Post) *SyncPlan {
+	plan := &SyncPlan{}
+	published := map[string]bool{}
+	for _, post := range posts {
+		published[post.Filename] = true
+
+		checksum, ok := manifest[post.Filename]
+		if !ok {
+			plan.Remove = append(plan.Remove, post)
+		} else if checksum != "" && checksum != fmt.Sprintf("%x", sha256.Sum256([]byte(post.Text))) {
+			plan.Update = append(plan.Update, post.Filename)
+		} else {
+			plan.Unchanged = append(plan.Unchanged, post.Filename)
+		}
+	}
+
+	for filename := range manifest {
+		if !published[filename] {
+			plan.Add = append(plan.Add, filename)
+		}
+	}
+
+	sort.Strings(plan.Add)
+	sort.Strings(plan.Update)
+	sort.Strings(plan.Unchanged)
+	return plan
+}
+
+func (p *SyncPlan) String() string {
+	rows := [][]string{}
+	for _, filename := range p.Add {
+		rows = append(rows, []string{"+", fmt.Sprintf("%s.txt", filename), "not published, upload it with scp"})
+	}
+	for _, filename := range p.Update {
+		rows = append(rows, []string{"~", fmt.Sprintf("%s.txt", filename), "differs from the published post, upload it with scp"})
+	}
+	for _, post := range p.Remove {
+		rows = append(rows, []string{"-", post.Filename, "missing locally"})
+	}
+
+	summary := fmt.Sprintf(
+		"%d to add, %d to update, %d to remove, %d unchanged",
+		len(p.Add), len(p.Update), len(p.Remove), len(p.Unchanged),
+	)
+	if len(rows) == 0 {
+		return summary
+	}
+	return fmt.Sprintf("%s\n\n%s", writeTable(rows), summary)
+}
+
+// Sync compares the manifest sent over stdin with every post, including
+// hidden and scheduled ones since those would otherwise never be pruned.
+// Nothing is changed unless --prune is passed.
+func (h *DbHandler) Sync(s ssh.Session, user *db.User, args []string) (string, error) {
+	prune := slices.Contains(args, "--prune")
+
+	manifest, err := ParseManifest(s)
+	if err != nil {
+		return "", err
+	}
+
+	// an empty manifest is almost always a mistake in the pipe
+	if len(manifest) == 0 {
+		return "", fmt.Errorf("no .txt files were sent, try: ls *.txt | ssh %s sync", h.Cfg.Domain)
+	}
+
+	posts, err := h.DBPool.FindAllPostsForUser(user.ID, h.Cfg.Space)
+	if err != nil {
+		return "", err
+	}
+
+	plan := PlanSync(manifest, posts)
+	if !prune {
+		msg := fmt.Sprintf("dry run, nothing was changed\n\n%s", plan)
+		if len(plan.Remove) > 0 {
+			msg += "\n\nrun again with --prune to remove the posts missing locally"
+		}
+		return msg, nil
+	}
+
+	postIDs := []string{}
+	for _, post := range plan.Remove {
+		postIDs = append(postIDs, post.ID)
+	}
+
+	if len(postIDs) > 0 {
+		err = h.DBPool.RemovePosts(postIDs)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	for _, post := range plan.Remove {
+		h.Cfg.Logger.Infof("(%s) missing locally, removed by sync", post.Filename)
+	}
+	return fmt.Sprintf("removed %d posts\n\n%s", len(plan.Remove), plan), nil
+}
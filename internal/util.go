@@ -4,6 +4,7 @@ import (
 	"encoding/base64"
 	"fmt"
 	"math"
+	"net/url"
 	"os"
 	pathpkg "path"
 	"path/filepath"
@@ -13,6 +14,7 @@ import (
 	"unicode"
 	"unicode/utf8"
 
+	"git.sr.ht/~erock/lists.sh/internal/storage"
 	"github.com/gliderlabs/ssh"
 	"golang.org/x/exp/slices"
 )
@@ -86,6 +88,44 @@ func IsTextFile(text string, filename string) bool {
 	return IsText(text[0:int(num)])
 }
 
+// PageURL links to a page of results, keeping the search query around.
+func PageURL(base string, query string, page int) string {
+	if query == "" {
+		return fmt.Sprintf("%s?page=%d", base, page)
+	}
+	return fmt.Sprintf("%s?q=%s&page=%d", base, url.QueryEscape(query), page)
+}
+
+type SnippetPart struct {
+	Text  string
+	Match bool
+}
+
+// SplitSnippet breaks a search snippet into the parts that matched the
+// query and the text around them so templates can highlight the matches
+// without trusting the post text as html.
+func SplitSnippet(snippet string) []SnippetPart {
+	parts := []SnippetPart{}
+	snippet = strings.ReplaceAll(snippet, "\n", " ")
+	for i, chunk := range strings.Split(snippet, storage.SnippetStart) {
+		if i == 0 {
+			if chunk != "" {
+				parts = append(parts, SnippetPart{Text: chunk})
+			}
+			continue
+		}
+
+		match, rest, _ := strings.Cut(chunk, storage.SnippetStop)
+		if match != "" {
+			parts = append(parts, SnippetPart{Text: match, Match: true})
+		}
+		if rest != "" {
+			parts = append(parts, SnippetPart{Text: rest})
+		}
+	}
+	return parts
+}
+
 const solarYearSecs = 31556926
 
 func TimeAgo(t *time.Time) string {
@@ -0,0 +1,156 @@
+package pkg
+
+import "strings"
+
+var DiffSame = "same"
+var DiffAdded = "added"
+var DiffRemoved = "removed"
+
+// DiffItem is a single list item in a diff between two versions of a list.
+// Line is the item as it would be written in the source text.
+type DiffItem struct {
+	Op   string
+	Line string
+	Item *ListItem
+}
+
+// DiffItems compares every item, including nested ones, of two versions of
+// a list.  Items are matched by their source line so editing an item shows
+// up as the old item removed and the new one added.
+//
+// Items shared at the start and end of both lists are skipped before the
+// rest is compared with Myers' algorithm, which only needs memory for the
+// edits instead of a table of every item in one list against the other.
+func DiffItems(before, after []*ListItem) []*DiffItem {
+	a := diffLines(flattenItems(before))
+	b := diffLines(flattenItems(after))
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix].Line == b[prefix].Line {
+		prefix += 1
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix &&
+		a[len(a)-1-suffix].Line == b[len(b)-1-suffix].Line {
+		suffix += 1
+	}
+
+	diff := []*DiffItem{}
+	for _, line := range b[:prefix] {
+		diff = append(diff, &DiffItem{Op: DiffSame, Line: line.Line, Item: line.Item})
+	}
+	diff = append(diff, shortestEdit(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range b[len(b)-suffix:] {
+		diff = append(diff, &DiffItem{Op: DiffSame, Line: line.Line, Item: line.Item})
+	}
+
+	return diff
+}
+
+// shortestEdit finds the fewest removals and additions that turn a into b.
+// trace keeps the furthest reaching x for every diagonal k after each
+// number of edits d, which is all that is needed to walk the edits back.
+func shortestEdit(a, b []*DiffItem) []*DiffItem {
+	n := len(a)
+	m := len(b)
+	if n == 0 || m == 0 {
+		diff := []*DiffItem{}
+		for _, line := range a {
+			diff = append(diff, &DiffItem{Op: DiffRemoved, Line: line.Line, Item: line.Item})
+		}
+		for _, line := range b {
+			diff = append(diff, &DiffItem{Op: DiffAdded, Line: line.Line, Item: line.Item})
+		}
+		return diff
+	}
+
+	offset := n + m
+	v := make([]int, 2*offset+2)
+	trace := [][]int{}
+	edits := 0
+search:
+	for d := 0; d <= n+m; d++ {
+		// the diagonals reached after d-1 edits, indexed by k+d
+		trace = append(trace, append([]int{}, v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			x := 0
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x].Line == b[y].Line {
+				x += 1
+				y += 1
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				edits = d
+				break search
+			}
+		}
+	}
+
+	reversed := []*DiffItem{}
+	x, y := n, m
+	for d := edits; d > 0; d-- {
+		prev := trace[d]
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && prev[k-1+d] < prev[k+1+d]) {
+			prevK = k + 1
+		}
+		prevX := prev[prevK+d]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x -= 1
+			y -= 1
+			reversed = append(reversed, &DiffItem{Op: DiffSame, Line: b[y].Line, Item: b[y].Item})
+		}
+		if x == prevX {
+			y -= 1
+			reversed = append(reversed, &DiffItem{Op: DiffAdded, Line: b[y].Line, Item: b[y].Item})
+		} else {
+			x -= 1
+			reversed = append(reversed, &DiffItem{Op: DiffRemoved, Line: a[x].Line, Item: a[x].Item})
+		}
+	}
+	for x > 0 && y > 0 {
+		x -= 1
+		y -= 1
+		reversed = append(reversed, &DiffItem{Op: DiffSame, Line: b[y].Line, Item: b[y].Item})
+	}
+
+	diff := make([]*DiffItem, len(reversed))
+	for i, item := range reversed {
+		diff[len(reversed)-1-i] = item
+	}
+	return diff
+}
+
+// DiffStats counts the added and removed items in a diff.
+func DiffStats(diff []*DiffItem) (int, int) {
+	added := 0
+	removed := 0
+	for _, item := range diff {
+		if item.Op == DiffAdded {
+			added += 1
+		} else if item.Op == DiffRemoved {
+			removed += 1
+		}
+	}
+	return added, removed
+}
+
+func diffLines(items []*ListItem) []*DiffItem {
+	lines := make([]*DiffItem, len(items))
+	for i, item := range items {
+		lines[i] = &DiffItem{
+			Line: strings.Join(formatLine(item), "\n"),
+			Item: item,
+		}
+	}
+	return lines
+}
@@ -0,0 +1,115 @@
+package pkg
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func diffItemsFromLines(lines []string) []*ListItem {
+	items := []*ListItem{}
+	for _, line := range lines {
+		items = append(items, &ListItem{Value: line, IsText: true})
+	}
+	return items
+}
+
+// lcsLength is the slow reference the diff has to agree with.
+func lcsLength(a, b []string) int {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	return lcs[0][0]
+}
+
+func checkDiff(t *testing.T, before, after []string) {
+	t.Helper()
+	diff := DiffItems(diffItemsFromLines(before), diffItemsFromLines(after))
+
+	gotBefore := []string{}
+	gotAfter := []string{}
+	same := 0
+	for _, item := range diff {
+		switch item.Op {
+		case DiffSame:
+			gotBefore = append(gotBefore, item.Line)
+			gotAfter = append(gotAfter, item.Line)
+			same += 1
+		case DiffRemoved:
+			gotBefore = append(gotBefore, item.Line)
+		case DiffAdded:
+			gotAfter = append(gotAfter, item.Line)
+		}
+	}
+
+	if strings.Join(gotBefore, "\n") != strings.Join(before, "\n") {
+		t.Errorf("diff does not rebuild the old list: %v != %v", gotBefore, before)
+	}
+	if strings.Join(gotAfter, "\n") != strings.Join(after, "\n") {
+		t.Errorf("diff does not rebuild the new list: %v != %v", gotAfter, after)
+	}
+	if want := lcsLength(before, after); same != want {
+		t.Errorf("diff keeps %d items, want %d for %v -> %v", same, want, before, after)
+	}
+}
+
+func TestDiffItems(t *testing.T) {
+	cases := []struct {
+		name   string
+		before []string
+		after  []string
+	}{
+		{"empty", nil, nil},
+		{"first upload", nil, []string{"milk", "eggs"}},
+		{"cleared", []string{"milk", "eggs"}, nil},
+		{"unchanged", []string{"milk", "eggs"}, []string{"milk", "eggs"}},
+		{"append", []string{"milk"}, []string{"milk", "eggs"}},
+		{"prepend", []string{"milk"}, []string{"eggs", "milk"}},
+		{"edit middle", []string{"milk", "eggs", "bread"}, []string{"milk", "ham", "bread"}},
+		{"reorder", []string{"a", "b", "c", "d"}, []string{"d", "c", "b", "a"}},
+		{"duplicates", []string{"a", "a", "b", "a"}, []string{"a", "b", "a", "a"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			checkDiff(t, tc.before, tc.after)
+		})
+	}
+}
+
+func TestDiffItemsRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	words := []string{"a", "b", "c", "d", "e"}
+	randomLines := func() []string {
+		lines := make([]string, r.Intn(12))
+		for i := range lines {
+			lines[i] = words[r.Intn(len(words))]
+		}
+		return lines
+	}
+
+	for i := 0; i < 500; i++ {
+		checkDiff(t, randomLines(), randomLines())
+	}
+}
+
+func TestDiffItemsStats(t *testing.T) {
+	before := diffItemsFromLines([]string{"milk", "eggs", "bread"})
+	after := diffItemsFromLines([]string{"milk", "ham", "bread", "jam"})
+	added, removed := DiffStats(DiffItems(before, after))
+	if added != 2 || removed != 1 {
+		t.Errorf("got +%d -%d, want +2 -1", added, removed)
+	}
+}
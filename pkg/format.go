@@ -0,0 +1,120 @@
+package pkg
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FormatText serializes parsed text back into canonical list text: variables
+// come first, every token is followed by a single space, nested items are
+// indented by two spaces per level and preformatted blocks are always closed.
+func FormatText(parsed *ParsedText) string {
+	lines := FormatMetaData(parsed.MetaData)
+	if len(lines) > 0 {
+		lines = append(lines, "")
+	}
+
+	for i, item := range parsed.Items {
+		if i > 0 && (item.IsHeaderOne || item.IsHeaderTwo) {
+			lines = append(lines, "")
+		}
+		lines = append(lines, formatItem(item)...)
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// FormatMetaData returns the variable lines for every metadata field that
+// differs from its default value.
+func FormatMetaData(meta *MetaData) []string {
+	lines := []string{}
+	if meta == nil {
+		return lines
+	}
+
+	if meta.Title != "" {
+		lines = append(lines, formatVar("title", meta.Title))
+	}
+	if meta.Description != "" {
+		lines = append(lines, formatVar("description", meta.Description))
+	}
+	if meta.PublishAt != nil {
+		lines = append(lines, formatVar("publish_at", meta.PublishAt.Format("2006-01-02")))
+	}
+	if meta.ListType != "" && meta.ListType != "disc" {
+		lines = append(lines, formatVar("list_type", meta.ListType))
+	}
+	if meta.Toc != nil {
+		lines = append(lines, formatVar("toc", strconv.FormatBool(*meta.Toc)))
+	}
+	if len(meta.Tags) > 0 {
+		lines = append(lines, formatVar("tags", strings.Join(meta.Tags, ", ")))
+	}
+
+	keys := make([]string, 0, len(meta.Extra))
+	for key := range meta.Extra {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		lines = append(lines, formatVar(key, meta.Extra[key]))
+	}
+
+	return lines
+}
+
+func formatVar(key string, value string) string {
+	return fmt.Sprintf("%s %s %s", varToken, key, value)
+}
+
+func formatLink(token string, item *ListItem) string {
+	url := string(item.URL)
+	if item.Value == "" || item.Value == url {
+		return fmt.Sprintf("%s %s", token, url)
+	}
+	return fmt.Sprintf("%s %s %s", token, url, item.Value)
+}
+
+func formatItem(item *ListItem) []string {
+	lines := formatLine(item)
+	for _, child := range item.Children {
+		lines = append(lines, formatItem(child)...)
+	}
+	return lines
+}
+
+// formatLine returns the lines for a single item without its children.
+func formatLine(item *ListItem) []string {
+	indent := item.Indent()
+	lines := []string{}
+
+	if item.IsHeaderOne {
+		lines = append(lines, fmt.Sprintf("%s %s", headerOneToken, item.Value))
+	} else if item.IsHeaderTwo {
+		lines = append(lines, fmt.Sprintf("%s %s", headerTwoToken, item.Value))
+	} else if item.IsURL {
+		lines = append(lines, indent+formatLink(urlToken, item))
+	} else if item.IsImg {
+		lines = append(lines, indent+formatLink(imgToken, item))
+	} else if item.IsBlock {
+		lines = append(lines, fmt.Sprintf("%s%s %s", indent, blockToken, item.Value))
+	} else if item.IsTodo {
+		token := todoToken
+		if item.Checked {
+			token = doneToken
+		}
+		lines = append(lines, fmt.Sprintf("%s%s %s", indent, token, item.Value))
+	} else if item.IsPre {
+		lines = append(lines, indent+preToken+item.Value, indent+preToken)
+	} else {
+		lines = append(lines, indent+item.Value)
+	}
+
+	if item.explicitID {
+		lines[0] = fmt.Sprintf("%s {#%s}", lines[0], item.ID)
+	}
+
+	return lines
+}
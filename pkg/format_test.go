@@ -0,0 +1,78 @@
+package pkg
+
+import (
+	"reflect"
+	"testing"
+)
+
+var formatCorpus = map[string]string{
+	"empty": "",
+	"plain": "first\nsecond\n\n\nthird\n",
+	"variables": `=: title groceries
+=: description what to buy
+=: publish_at 2022-04-20
+=: list_type square
+=: toc false
+=: tags food,  weekly
+=: mood hungry
+milk
+`,
+	"nesting": `groceries
+  fruit
+	apples
+      bananas
+  => https://lists.sh a link inside the groceries list
+chores
+  [ ] walk the dog
+  [x] buy milk
+`,
+	"headers": `# one
+  indented header
+## two
+> quote
+=< https://i.imgur.com/iXMNUN5.jpg I use arch, btw
+=> https://lists.sh
+`,
+	"pre":          "before\n```\n#!/usr/bin/env bash\n\n  set -x\n```\nafter\n",
+	"unclosed pre": "before\n```\necho \"never closed\"\n",
+	"ids": `# Reading list {#books}
+=> https://example.com/dune Dune {#dune}
+walk the dog {#dog}
+same
+same
+` + "```{#not-an-id}\n```\n",
+	"rejected urls": `=> javascript:alert(1) click me
+=< data:image/png;base64,AAAA
+=> /relative/link relative
+=> mailto:hi@lists.sh
+`,
+}
+
+func TestFormatTextRoundTrip(t *testing.T) {
+	for name, text := range formatCorpus {
+		t.Run(name, func(t *testing.T) {
+			parsed := ParseText(text)
+			formatted := FormatText(parsed)
+			reparsed := ParseText(formatted)
+
+			if !reflect.DeepEqual(parsed.Items, reparsed.Items) {
+				t.Errorf("items changed after formatting:\n%s", formatted)
+			}
+			if !reflect.DeepEqual(parsed.MetaData, reparsed.MetaData) {
+				t.Errorf("metadata changed after formatting: %+v != %+v", parsed.MetaData, reparsed.MetaData)
+			}
+		})
+	}
+}
+
+func TestFormatTextIdempotent(t *testing.T) {
+	for name, text := range formatCorpus {
+		t.Run(name, func(t *testing.T) {
+			once := FormatText(ParseText(text))
+			twice := FormatText(ParseText(once))
+			if once != twice {
+				t.Errorf("formatting is not stable:\n%s\n---\n%s", once, twice)
+			}
+		})
+	}
+}
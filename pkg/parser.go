@@ -1,28 +1,60 @@
 package pkg
 
 import (
+	"crypto/sha1"
 	"fmt"
 	"html/template"
+	"net/url"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
+
+	"golang.org/x/exp/slices"
 )
 
 type ParsedText struct {
-	Items    []*ListItem
-	MetaData *MetaData
+	// Items are the top-level list items, nested items live in ListItem.Children
+	Items       []*ListItem
+	MetaData    *MetaData
+	Diagnostics []*Diagnostic
+}
+
+var SeverityError = "error"
+var SeverityWarning = "warning"
+
+// Diagnostic describes a problem found while parsing a line of text.
+// Line and Column are both 1-based.
+type Diagnostic struct {
+	Line     int
+	Column   int
+	Severity string
+	Message  string
+}
+
+func (d *Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: %s: %s", d.Line, d.Column, d.Severity, d.Message)
 }
 
 type ListItem struct {
-	Value       string
-	URL         template.URL
-	Variable    string
-	IsURL       bool
-	IsBlock     bool
-	IsText      bool
-	IsHeaderOne bool
-	IsHeaderTwo bool
-	IsImg       bool
-	IsPre       bool
+	// ID is unique within a list, it comes from a `{#id}` marker at the end
+	// of the line or is derived from the content of the item
+	ID          string       `json:"id,omitempty"`
+	Value       string       `json:"value"`
+	URL         template.URL `json:"url,omitempty"`
+	Variable    string       `json:"variable,omitempty"`
+	IsURL       bool         `json:"is_url,omitempty"`
+	IsBlock     bool         `json:"is_block,omitempty"`
+	IsText      bool         `json:"is_text,omitempty"`
+	IsHeaderOne bool         `json:"is_header_one,omitempty"`
+	IsHeaderTwo bool         `json:"is_header_two,omitempty"`
+	IsImg       bool         `json:"is_img,omitempty"`
+	IsPre       bool         `json:"is_pre,omitempty"`
+	IsTodo      bool         `json:"is_todo,omitempty"`
+	Checked     bool         `json:"checked,omitempty"`
+	Depth       int          `json:"depth"`
+	Children    []*ListItem  `json:"children,omitempty"`
+	explicitID  bool
 }
 
 type MetaData struct {
@@ -30,6 +62,12 @@ type MetaData struct {
 	Title       string
 	Description string
 	ListType    string // https://developer.mozilla.org/en-US/docs/Web/CSS/list-style-type
+	Tags        []string
+	// Toc forces the table of contents on or off, when nil it depends on
+	// the number of headers
+	Toc *bool
+	// Extra holds every variable that does not map to a known field
+	Extra map[string]string
 }
 
 var urlToken = "=>"
@@ -39,6 +77,21 @@ var imgToken = "=<"
 var headerOneToken = "#"
 var headerTwoToken = "##"
 var preToken = "```"
+var todoToken = "[ ]"
+var doneToken = "[x]"
+
+// AllowedURLSchemes are the url schemes links and images may use by default,
+// relative urls are always allowed.  Use ParseOptions to allow others.
+var AllowedURLSchemes = []string{"http", "https", "gemini", "mailto"}
+
+// ParseOptions change how text is parsed.
+type ParseOptions struct {
+	// URLSchemes replaces AllowedURLSchemes when it is not nil.
+	URLSchemes []string
+}
+
+var itemIDRe = regexp.MustCompile(`\s*\{#([A-Za-z0-9_-]+)\}$`)
+var slugRe = regexp.MustCompile(`[^a-z0-9]+`)
 
 type SplitToken struct {
 	Key   string
@@ -67,6 +120,26 @@ func TextToSplitToken(text string) *SplitToken {
 	return token
 }
 
+// tabWidth is how many spaces a tab character counts for when measuring
+// the indentation of a line.
+var tabWidth = 4
+
+// SplitIndent separates the leading whitespace from a line and returns the
+// width of that indentation along with the remaining text.
+func SplitIndent(text string) (int, string) {
+	width := 0
+	for i, c := range text {
+		if c == ' ' {
+			width += 1
+		} else if c == '\t' {
+			width += tabWidth
+		} else {
+			return width, text[i:]
+		}
+	}
+	return width, ""
+}
+
 func SplitByNewline(text string) []string {
 	return strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
 }
@@ -76,19 +149,63 @@ func PublishAtDate(date string) (*time.Time, error) {
 	return &t, err
 }
 
-func TokenToMetaField(meta *MetaData, token *SplitToken) {
+func TokenToMetaField(meta *MetaData, token *SplitToken) error {
 	if token.Key == "publish_at" {
 		publishAt, err := PublishAtDate(token.Value)
-		if err == nil {
-			meta.PublishAt = publishAt
+		if err != nil {
+			return fmt.Errorf("invalid publish_at date (%s), format must be YYYY-MM-DD", token.Value)
 		}
+		meta.PublishAt = publishAt
 	} else if token.Key == "title" {
 		meta.Title = token.Value
 	} else if token.Key == "description" {
 		meta.Description = token.Value
 	} else if token.Key == "list_type" {
 		meta.ListType = token.Value
+	} else if token.Key == "toc" {
+		toc, err := strconv.ParseBool(token.Value)
+		if err != nil {
+			return fmt.Errorf("invalid toc value (%s), must be true or false", token.Value)
+		}
+		meta.Toc = &toc
+	} else if token.Key == "tags" {
+		for _, tag := range SplitTags(token.Value) {
+			if !slices.Contains(meta.Tags, tag) {
+				meta.Tags = append(meta.Tags, tag)
+			}
+		}
+	} else if token.Key == "" {
+		return fmt.Errorf("variable is missing a key")
+	} else {
+		meta.Extra[token.Key] = token.Value
+	}
+
+	return nil
+}
+
+// SplitTags turns a comma separated list of tags into normalized tag names.
+func SplitTags(value string) []string {
+	tags := []string{}
+	for _, tag := range strings.Split(value, ",") {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// IsAllowedURL reports whether the url is relative or uses one of the
+// allowed schemes.
+func IsAllowedURL(rawURL string, schemes []string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	if u.Scheme == "" {
+		return true
 	}
+	return slices.Contains(schemes, u.Scheme)
 }
 
 func KeyAsValue(token *SplitToken) string {
@@ -98,30 +215,59 @@ func KeyAsValue(token *SplitToken) string {
 	return token.Value
 }
 
+type indentedItem struct {
+	indent int
+	item   *ListItem
+}
+
 func ParseText(text string) *ParsedText {
+	return ParseTextWithOptions(text, &ParseOptions{})
+}
+
+func ParseTextWithOptions(text string, opts *ParseOptions) *ParsedText {
+	schemes := AllowedURLSchemes
+	if opts.URLSchemes != nil {
+		schemes = opts.URLSchemes
+	}
+
 	textItems := SplitByNewline(text)
 	items := []*ListItem{}
 	meta := &MetaData{
 		ListType: "disc",
+		Tags:     []string{},
+		Extra:    map[string]string{},
 	}
+	diagnostics := []*Diagnostic{}
 	pre := false
+	preLine := 0
+	preColumn := 0
 	skip := false
 	var prevItem *ListItem
+	// parents tracks the chain of items that the next line could be nested under
+	parents := []indentedItem{}
+	explicitIDs := map[string]bool{}
 
-	for _, t := range textItems {
+	for i, t := range textItems {
 		skip = false
+		lineNum := i + 1
 
-		if len(items) > 0 {
-			prevItem = items[len(items)-1]
+		indent, line := SplitIndent(t)
+		column := len(t) - len(line) + 1
+		li := &ListItem{
+			Value: strings.Trim(line, " "),
 		}
 
-		li := &ListItem{
-			Value: strings.Trim(t, " "),
+		id := ""
+		if !pre && !strings.HasPrefix(li.Value, preToken) && !strings.HasPrefix(li.Value, varToken) {
+			id, li.Value = SplitItemID(li.Value)
 		}
+		value := li.Value
 
 		if strings.HasPrefix(li.Value, preToken) {
 			pre = !pre
 			if pre {
+				preLine = lineNum
+				preColumn = column
 				nextValue := strings.Replace(li.Value, preToken, "", 1)
 				li.IsPre = true
 				li.Value = nextValue
@@ -129,9 +275,9 @@ func ParseText(text string) *ParsedText {
 				skip = true
 			}
 		} else if pre {
-			nextValue := strings.Replace(li.Value, preToken, "", 1)
+			nextValue := strings.Replace(strings.Trim(t, " "), preToken, "", 1)
 			prevItem.Value = fmt.Sprintf("%s\n%s", prevItem.Value, nextValue)
-			skip = true
+			continue
 		} else if strings.HasPrefix(li.Value, urlToken) {
 			li.IsURL = true
 			split := TextToSplitToken(strings.Replace(li.Value, urlToken, "", 1))
@@ -139,7 +285,7 @@ func ParseText(text string) *ParsedText {
 			li.Value = KeyAsValue(split)
 		} else if strings.HasPrefix(li.Value, blockToken) {
 			li.IsBlock = true
-			li.Value = strings.Replace(li.Value, blockToken, "", 1)
+			li.Value = strings.Trim(strings.Replace(li.Value, blockToken, "", 1), " ")
 		} else if strings.HasPrefix(li.Value, imgToken) {
 			li.IsImg = true
 			split := TextToSplitToken(strings.Replace(li.Value, imgToken, "", 1))
@@ -147,29 +293,240 @@ func ParseText(text string) *ParsedText {
 			li.Value = KeyAsValue(split)
 		} else if strings.HasPrefix(li.Value, varToken) {
 			split := TextToSplitToken(strings.Replace(li.Value, varToken, "", 1))
-			TokenToMetaField(meta, split)
+			err := TokenToMetaField(meta, split)
+			if err != nil {
+				diagnostics = append(diagnostics, &Diagnostic{
+					Line:     lineNum,
+					Column:   column,
+					Severity: SeverityError,
+					Message:  err.Error(),
+				})
+			}
 			continue
+		} else if strings.HasPrefix(li.Value, todoToken) {
+			li.IsTodo = true
+			li.Value = strings.Trim(strings.Replace(li.Value, todoToken, "", 1), " ")
+		} else if strings.HasPrefix(strings.ToLower(li.Value), doneToken) {
+			li.IsTodo = true
+			li.Checked = true
+			li.Value = strings.Trim(li.Value[len(doneToken):], " ")
 		} else if strings.HasPrefix(li.Value, headerTwoToken) {
 			li.IsHeaderTwo = true
-			li.Value = strings.Replace(li.Value, headerTwoToken, "", 1)
+			li.Value = strings.Trim(strings.Replace(li.Value, headerTwoToken, "", 1), " ")
 		} else if strings.HasPrefix(li.Value, headerOneToken) {
 			li.IsHeaderOne = true
-			li.Value = strings.Replace(li.Value, headerOneToken, "", 1)
+			li.Value = strings.Trim(strings.Replace(li.Value, headerOneToken, "", 1), " ")
 		} else {
 			li.IsText = true
 		}
 
+		if (li.IsURL || li.IsImg) && !IsAllowedURL(string(li.URL), schemes) {
+			diagnostics = append(diagnostics, &Diagnostic{
+				Line:     lineNum,
+				Column:   column,
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("url (%s) is not allowed, it will be rendered as text", li.URL),
+			})
+			li = &ListItem{
+				Value:  value,
+				IsText: true,
+			}
+		}
+
 		if li.IsText && li.Value == "" {
 			skip = true
 		}
 
-		if !skip {
+		if skip {
+			continue
+		}
+
+		prevItem = li
+
+		if id != "" {
+			if explicitIDs[id] {
+				diagnostics = append(diagnostics, &Diagnostic{
+					Line:     lineNum,
+					Column:   column,
+					Severity: SeverityWarning,
+					Message:  fmt.Sprintf("id (%s) is already used, a suffix will be added", id),
+				})
+			}
+			explicitIDs[id] = true
+			li.ID = id
+			li.explicitID = true
+		}
+
+		// headers end the current list so they can never be nested
+		if li.IsHeaderOne || li.IsHeaderTwo {
+			parents = parents[:0]
 			items = append(items, li)
+			continue
 		}
+
+		for len(parents) > 0 && parents[len(parents)-1].indent >= indent {
+			parents = parents[:len(parents)-1]
+		}
+
+		if len(parents) == 0 {
+			items = append(items, li)
+		} else {
+			parent := parents[len(parents)-1].item
+			li.Depth = parent.Depth + 1
+			parent.Children = append(parent.Children, li)
+		}
+
+		parents = append(parents, indentedItem{indent: indent, item: li})
 	}
 
+	if pre {
+		diagnostics = append(diagnostics, &Diagnostic{
+			Line:     preLine,
+			Column:   preColumn,
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("preformatted block is never closed with %s, it contains the rest of the file", preToken),
+		})
+	}
+
+	assignItemIDs(items)
+
 	return &ParsedText{
-		Items:    items,
-		MetaData: meta,
+		Items:       items,
+		MetaData:    meta,
+		Diagnostics: diagnostics,
 	}
 }
+
+// SplitItemID removes a trailing `{#id}` marker from the text of an item
+// and returns the id along with the remaining text.
+func SplitItemID(value string) (string, string) {
+	match := itemIDRe.FindStringSubmatchIndex(value)
+	if match == nil {
+		return "", value
+	}
+	return value[match[2]:match[3]], value[:match[0]]
+}
+
+// Slugify turns text into a lowercase, hyphen separated anchor.
+func Slugify(text string) string {
+	return strings.Trim(slugRe.ReplaceAllString(strings.ToLower(text), "-"), "-")
+}
+
+// contentID is the id for items without a marker.  Headers get readable
+// slugs while everything else gets a short hash so the id only changes
+// when the item itself does.
+func contentID(item *ListItem) string {
+	if item.IsHeaderOne || item.IsHeaderTwo {
+		slug := Slugify(item.Value)
+		if slug != "" {
+			return slug
+		}
+	}
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s\x00%s", item.Value, item.URL)))
+	return fmt.Sprintf("item-%x", sum[:4])
+}
+
+// assignItemIDs derives ids for items without a marker and makes every id
+// unique by suffixing repeats with a counter.  Markers claim their id first
+// so a derived id can never take it from them.
+func assignItemIDs(items []*ListItem) {
+	all := flattenItems(items)
+	used := map[string]bool{}
+	for _, item := range all {
+		if item.explicitID {
+			used[item.ID] = true
+		}
+	}
+
+	claimed := map[string]bool{}
+	for _, item := range all {
+		base := item.ID
+		if !item.explicitID {
+			base = contentID(item)
+		} else if !claimed[base] {
+			claimed[base] = true
+			continue
+		}
+
+		id := base
+		for n := 2; used[id]; n++ {
+			id = base + "-" + strconv.Itoa(n)
+		}
+		used[id] = true
+		item.ID = id
+	}
+}
+
+// FindItem returns the item, nested or not, with the id.
+func (p *ParsedText) FindItem(id string) *ListItem {
+	for _, item := range p.AllItems() {
+		if item.ID == id {
+			return item
+		}
+	}
+	return nil
+}
+
+// TocMinHeaders is how many headers a list needs before it gets a table of
+// contents without asking for one.
+var TocMinHeaders = 4
+
+// Toc returns the headers to link to from a table of contents, or nothing
+// when the list should not have one.
+func (p *ParsedText) Toc() []*ListItem {
+	headers := []*ListItem{}
+	for _, item := range p.Items {
+		if item.IsHeaderOne || item.IsHeaderTwo {
+			headers = append(headers, item)
+		}
+	}
+
+	if p.MetaData != nil && p.MetaData.Toc != nil {
+		if *p.MetaData.Toc {
+			return headers
+		}
+		return []*ListItem{}
+	}
+
+	if len(headers) < TocMinHeaders {
+		return []*ListItem{}
+	}
+	return headers
+}
+
+// AllItems returns every list item, including nested ones, in document order.
+func (p *ParsedText) AllItems() []*ListItem {
+	return flattenItems(p.Items)
+}
+
+// TodoProgress returns how many todo items are checked and how many todo
+// items exist in total.
+func (p *ParsedText) TodoProgress() (int, int) {
+	done := 0
+	total := 0
+	for _, item := range p.AllItems() {
+		if !item.IsTodo {
+			continue
+		}
+		total += 1
+		if item.Checked {
+			done += 1
+		}
+	}
+	return done, total
+}
+
+func flattenItems(items []*ListItem) []*ListItem {
+	all := []*ListItem{}
+	for _, item := range items {
+		all = append(all, item)
+		all = append(all, flattenItems(item.Children)...)
+	}
+	return all
+}
+
+// Indent returns leading whitespace matching the nesting depth of the item
+// for formats that have no native way to nest lists.
+func (li *ListItem) Indent() string {
+	return strings.Repeat("  ", li.Depth)
+}
@@ -0,0 +1,23 @@
+package pkg
+
+import "testing"
+
+func TestParseTextWithOptionsURLSchemes(t *testing.T) {
+	text := "=> gopher://example.com hole\n=> https://lists.sh lists\n"
+
+	parsed := ParseText(text)
+	if parsed.Items[0].IsURL || !parsed.Items[1].IsURL {
+		t.Errorf("default schemes: got %+v %+v", parsed.Items[0], parsed.Items[1])
+	}
+
+	parsed = ParseTextWithOptions(text, &ParseOptions{URLSchemes: []string{"gopher"}})
+	if !parsed.Items[0].IsURL || parsed.Items[1].IsURL {
+		t.Errorf("gopher only: got %+v %+v", parsed.Items[0], parsed.Items[1])
+	}
+
+	// options never leak into the defaults
+	parsed = ParseText(text)
+	if parsed.Items[0].IsURL {
+		t.Errorf("gopher is allowed after parsing with options: %+v", parsed.Items[0])
+	}
+}
@@ -0,0 +1,52 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"git.sr.ht/~erock/lists.sh/pkg"
+)
+
+// GemtextRenderer renders lists as gemtext.  Gemtext cannot nest lists so
+// nested text items are indented instead.
+type GemtextRenderer struct{}
+
+func (r *GemtextRenderer) Render(w io.Writer, parsed *pkg.ParsedText) error {
+	lines := []string{}
+	for _, item := range parsed.Items {
+		if item.IsHeaderOne {
+			lines = append(lines, "", fmt.Sprintf("## %s", item.Value))
+		} else if item.IsHeaderTwo {
+			lines = append(lines, "", fmt.Sprintf("### %s", item.Value))
+		} else {
+			lines = append(lines, gemtextItem(item)...)
+		}
+	}
+	return writeLines(w, lines)
+}
+
+func gemtextItem(item *pkg.ListItem) []string {
+	lines := []string{}
+	if item.IsURL || item.IsImg {
+		lines = append(lines, fmt.Sprintf("=> %s %s", item.URL, item.Value))
+	} else if item.IsBlock {
+		lines = append(lines, fmt.Sprintf("> %s", item.Value))
+	} else if item.IsTodo {
+		check := "[ ]"
+		if item.Checked {
+			check = "[x]"
+		}
+		lines = append(lines, fmt.Sprintf("%s* %s %s", item.Indent(), check, item.Value))
+	} else if item.IsPre {
+		lines = append(lines, "```", strings.TrimPrefix(item.Value, "\n"), "```")
+	} else if item.IsText && item.Value != "" {
+		lines = append(lines, fmt.Sprintf("%s* %s", item.Indent(), item.Value))
+	}
+
+	for _, child := range item.Children {
+		lines = append(lines, gemtextItem(child)...)
+	}
+
+	return lines
+}
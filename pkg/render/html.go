@@ -0,0 +1,102 @@
+package render
+
+import (
+	"fmt"
+	"html"
+	"io"
+
+	"git.sr.ht/~erock/lists.sh/pkg"
+)
+
+// HTMLRenderer renders lists as html where headers split the document into
+// multiple lists.
+type HTMLRenderer struct {
+	// Anchors adds item ids and permalinks that show up on hover
+	Anchors bool
+	// Feed renders todos as text since feed readers strip form inputs
+	Feed bool
+}
+
+func (r *HTMLRenderer) Render(w io.Writer, parsed *pkg.ParsedText) error {
+	listOpen := fmt.Sprintf(`<ul style="list-style-type: %s;">`, html.EscapeString(ListType(parsed)))
+	lines := []string{listOpen}
+
+	for _, item := range parsed.Items {
+		value := html.EscapeString(item.Value) + r.anchor(item)
+		if item.IsHeaderOne {
+			lines = append(lines, fmt.Sprintf(`</ul><h2%s class="text-xl font-bold">%s</h2>%s`, r.idAttr(item), value, listOpen))
+		} else if item.IsHeaderTwo {
+			lines = append(lines, fmt.Sprintf(`</ul><h3%s class="text-lg font-bold">%s</h3>%s`, r.idAttr(item), value, listOpen))
+		} else {
+			lines = append(lines, r.htmlItem(item)...)
+		}
+	}
+
+	lines = append(lines, "</ul>")
+	return writeLines(w, lines)
+}
+
+func (r *HTMLRenderer) idAttr(item *pkg.ListItem) string {
+	if !r.Anchors || item.ID == "" {
+		return ""
+	}
+	return fmt.Sprintf(` id="%s"`, html.EscapeString(item.ID))
+}
+
+func (r *HTMLRenderer) anchor(item *pkg.ListItem) string {
+	if !r.Anchors || item.ID == "" {
+		return ""
+	}
+	return fmt.Sprintf(` <a href="#%s" class="anchor" aria-label="permalink">#</a>`, html.EscapeString(item.ID))
+}
+
+func (r *HTMLRenderer) htmlItem(item *pkg.ListItem) []string {
+	value := html.EscapeString(item.Value)
+	url := html.EscapeString(string(item.URL))
+
+	var content string
+	if item.IsURL {
+		content = fmt.Sprintf(`<a href="%s">%s</a>`, url, value)
+	} else if item.IsImg {
+		content = fmt.Sprintf(`<img src="%s" alt="%s" />`, url, value)
+	} else if item.IsBlock {
+		content = fmt.Sprintf("<blockquote>%s</blockquote>", value)
+	} else if item.IsTodo && r.Feed {
+		box := "☐"
+		if item.Checked {
+			box = "☑"
+		}
+		content = fmt.Sprintf("%s %s", box, value)
+	} else if item.IsTodo {
+		checked := ""
+		if item.Checked {
+			checked = " checked"
+		}
+		content = fmt.Sprintf(`<input type="checkbox" disabled%s /> %s`, checked, value)
+	} else if item.IsPre {
+		content = fmt.Sprintf("<pre>%s</pre>", value)
+	} else if item.IsText && item.Value != "" {
+		content = value
+	} else {
+		return []string{}
+	}
+
+	content += r.anchor(item)
+
+	open := fmt.Sprintf("<li%s>", r.idAttr(item))
+	if item.IsTodo {
+		open = fmt.Sprintf(`<li%s class="todo">`, r.idAttr(item))
+	}
+
+	if len(item.Children) == 0 {
+		return []string{open + content + "</li>"}
+	}
+
+	lines := []string{open + content, "<ul>"}
+	for _, child := range item.Children {
+		lines = append(lines, r.htmlItem(child)...)
+	}
+	lines = append(lines, "</ul>", "</li>")
+
+	return lines
+}
@@ -0,0 +1,104 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"git.sr.ht/~erock/lists.sh/pkg"
+)
+
+// MarkdownRenderer renders lists as CommonMark with GFM task list items.
+type MarkdownRenderer struct{}
+
+func (r *MarkdownRenderer) Render(w io.Writer, parsed *pkg.ParsedText) error {
+	lines := []string{}
+	for i, item := range parsed.Items {
+		if item.IsHeaderOne || item.IsHeaderTwo {
+			if i > 0 {
+				lines = append(lines, "")
+			}
+			level := "##"
+			if item.IsHeaderTwo {
+				level = "###"
+			}
+			lines = append(lines, fmt.Sprintf("%s %s", level, escapeMarkdown(item.Value)), "")
+		} else {
+			lines = append(lines, markdownItem(item)...)
+		}
+	}
+	return writeLines(w, lines)
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"`", "\\`",
+	`*`, `\*`,
+	`_`, `\_`,
+	`[`, `\[`,
+	`]`, `\]`,
+	`<`, `\<`,
+	`>`, `\>`,
+	`#`, `\#`,
+	`|`, `\|`,
+)
+
+// text that starts like a list marker or a thematic break would otherwise
+// become a nested block
+var bulletMarkerRe = regexp.MustCompile(`^(\s*)([-+])`)
+var orderedMarkerRe = regexp.MustCompile(`^(\s*\d{1,9})([.)])(\s|$)`)
+
+func escapeMarkdown(text string) string {
+	text = markdownEscaper.Replace(text)
+	text = bulletMarkerRe.ReplaceAllString(text, `${1}\${2}`)
+	return orderedMarkerRe.ReplaceAllString(text, `${1}\${2}${3}`)
+}
+
+var markdownURLEscaper = strings.NewReplacer(
+	" ", "%20",
+	"(", "%28",
+	")", "%29",
+	"<", "%3C",
+	">", "%3E",
+)
+
+func markdownItem(item *pkg.ListItem) []string {
+	// list items nest when indented past the "- " marker of their parent
+	indent := strings.Repeat("  ", item.Depth)
+	value := escapeMarkdown(item.Value)
+	url := markdownURLEscaper.Replace(string(item.URL))
+
+	lines := []string{}
+	if item.IsURL {
+		lines = append(lines, fmt.Sprintf("%s- [%s](%s)", indent, value, url))
+	} else if item.IsImg {
+		lines = append(lines, fmt.Sprintf("%s- ![%s](%s)", indent, value, url))
+	} else if item.IsBlock {
+		lines = append(lines, fmt.Sprintf("%s- > %s", indent, value))
+	} else if item.IsTodo {
+		check := "[ ]"
+		if item.Checked {
+			check = "[x]"
+		}
+		lines = append(lines, fmt.Sprintf("%s- %s %s", indent, check, value))
+	} else if item.IsPre {
+		fence := "```"
+		for strings.Contains(item.Value, fence) {
+			fence += "`"
+		}
+		lines = append(lines, fmt.Sprintf("%s- %s", indent, fence))
+		for _, line := range strings.Split(strings.TrimPrefix(item.Value, "\n"), "\n") {
+			lines = append(lines, fmt.Sprintf("%s  %s", indent, line))
+		}
+		lines = append(lines, fmt.Sprintf("%s  %s", indent, fence))
+	} else if item.IsText && item.Value != "" {
+		lines = append(lines, fmt.Sprintf("%s- %s", indent, value))
+	}
+
+	for _, child := range item.Children {
+		lines = append(lines, markdownItem(child)...)
+	}
+
+	return lines
+}
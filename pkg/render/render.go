@@ -0,0 +1,57 @@
+package render
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+
+	"git.sr.ht/~erock/lists.sh/pkg"
+)
+
+// Renderer turns parsed list text into a single output format.
+type Renderer interface {
+	Render(w io.Writer, parsed *pkg.ParsedText) error
+}
+
+// ToString renders the parsed text into a string.
+func ToString(r Renderer, parsed *pkg.ParsedText) (string, error) {
+	var buf bytes.Buffer
+	err := r.Render(&buf, parsed)
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Items wraps list items in parsed text so they can be rendered on their own.
+func Items(items []*pkg.ListItem, listType string) *pkg.ParsedText {
+	return &pkg.ParsedText{
+		Items: items,
+		MetaData: &pkg.MetaData{
+			ListType: listType,
+		},
+	}
+}
+
+// writeLines writes every line followed by a newline, stopping at the first
+// error.
+func writeLines(w io.Writer, lines []string) error {
+	for _, line := range lines {
+		_, err := io.WriteString(w, line+"\n")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var listTypeRe = regexp.MustCompile(`^([a-zA-Z][a-zA-Z0-9-]*|"[^"\\<>]*")$`)
+
+// ListType returns the css list-style-type for the parsed text, falling back
+// to the default when the value could escape the style attribute.
+func ListType(parsed *pkg.ParsedText) string {
+	if parsed.MetaData == nil || !listTypeRe.MatchString(parsed.MetaData.ListType) {
+		return "disc"
+	}
+	return parsed.MetaData.ListType
+}
@@ -0,0 +1,54 @@
+package render
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"git.sr.ht/~erock/lists.sh/pkg"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+func TestRenderGolden(t *testing.T) {
+	renderers := map[string]Renderer{
+		"html":         &HTMLRenderer{},
+		"anchors.html": &HTMLRenderer{Anchors: true},
+		"feed.html":    &HTMLRenderer{Feed: true},
+		"gmi":          &GemtextRenderer{},
+		"md":           &MarkdownRenderer{},
+		"txt":          &TextRenderer{},
+	}
+
+	text, err := os.ReadFile(filepath.Join("testdata", "list.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed := pkg.ParseText(string(text))
+
+	for ext, renderer := range renderers {
+		t.Run(ext, func(t *testing.T) {
+			actual, err := ToString(renderer, parsed)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			golden := filepath.Join("testdata", "list."+ext+".golden")
+			if *update {
+				err = os.WriteFile(golden, []byte(actual), 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			expected, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual != string(expected) {
+				t.Errorf("%s does not match, run `go test ./pkg/render -update` if the change is expected\n%s", golden, actual)
+			}
+		})
+	}
+}
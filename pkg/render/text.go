@@ -0,0 +1,71 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"git.sr.ht/~erock/lists.sh/pkg"
+)
+
+// TextRenderer renders lists as plain text meant to be read in a terminal.
+type TextRenderer struct{}
+
+func (r *TextRenderer) Render(w io.Writer, parsed *pkg.ParsedText) error {
+	lines := []string{}
+	for i, item := range parsed.Items {
+		if item.IsHeaderOne || item.IsHeaderTwo {
+			if i > 0 {
+				lines = append(lines, "")
+			}
+			underline := "="
+			if item.IsHeaderTwo {
+				underline = "-"
+			}
+			lines = append(
+				lines,
+				item.Value,
+				strings.Repeat(underline, len([]rune(item.Value))),
+			)
+		} else {
+			lines = append(lines, textItem(item)...)
+		}
+	}
+	return writeLines(w, lines)
+}
+
+func textItem(item *pkg.ListItem) []string {
+	indent := strings.Repeat("  ", item.Depth)
+	url := string(item.URL)
+
+	lines := []string{}
+	if item.IsURL {
+		if item.Value == url {
+			lines = append(lines, fmt.Sprintf("%s- %s", indent, url))
+		} else {
+			lines = append(lines, fmt.Sprintf("%s- %s <%s>", indent, item.Value, url))
+		}
+	} else if item.IsImg {
+		lines = append(lines, fmt.Sprintf("%s- [image: %s] <%s>", indent, item.Value, url))
+	} else if item.IsBlock {
+		lines = append(lines, fmt.Sprintf("%s  > %s", indent, item.Value))
+	} else if item.IsTodo {
+		check := "[ ]"
+		if item.Checked {
+			check = "[x]"
+		}
+		lines = append(lines, fmt.Sprintf("%s%s %s", indent, check, item.Value))
+	} else if item.IsPre {
+		for _, line := range strings.Split(strings.TrimPrefix(item.Value, "\n"), "\n") {
+			lines = append(lines, fmt.Sprintf("%s    %s", indent, line))
+		}
+	} else if item.IsText && item.Value != "" {
+		lines = append(lines, fmt.Sprintf("%s- %s", indent, item.Value))
+	}
+
+	for _, child := range item.Children {
+		lines = append(lines, textItem(child)...)
+	}
+
+	return lines
+}